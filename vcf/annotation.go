@@ -0,0 +1,199 @@
+package vcf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Annotation is the consequence of an ALT allele for one transcript, or
+// other feature, from a VEP CSQ or SnpEff ANN INFO field.
+type Annotation struct {
+	// Fields holds the values of the annotation by the names given in the
+	// header description, for example, "SYMBOL" or "Gene_Name".
+	Fields map[string]string
+	// AltIndex is the index in Variant.Alt of the annotated allele, or -1
+	// if it can not be found.
+	AltIndex int
+}
+
+// Get returns the value of the first of keys the annotation has a value
+// for, or "" if it has none.
+func (a Annotation) Get(keys ...string) string {
+	for _, k := range keys {
+		if x := a.Fields[k]; x != "" {
+			return x
+		}
+	}
+	return ""
+}
+
+// Allele returns the annotated allele as written by the annotator. VEP
+// removes the first base of indels, which is shared with REF, and writes
+// "-" for deletions.
+func (a Annotation) Allele() string {
+	return a.Get("Allele")
+}
+
+// Gene returns the gene symbol.
+func (a Annotation) Gene() string {
+	return a.Get("SYMBOL", "Gene_Name")
+}
+
+// GeneID returns the stable ID of the gene.
+func (a Annotation) GeneID() string {
+	return a.Get("Gene", "Gene_ID")
+}
+
+// Transcript returns the ID of the transcript, or other feature.
+func (a Annotation) Transcript() string {
+	return a.Get("Feature", "Feature_ID")
+}
+
+// Consequences returns the consequence terms, for example,
+// "missense_variant" and "splice_region_variant".
+func (a Annotation) Consequences() []string {
+	x := a.Get("Consequence", "Annotation")
+	if x == "" {
+		return nil
+	}
+	return strings.Split(x, "&")
+}
+
+// Impact returns the impact of the consequences: HIGH, MODERATE, LOW or
+// MODIFIER.
+func (a Annotation) Impact() string {
+	return a.Get("IMPACT", "Annotation_Impact")
+}
+
+// HGVSc returns the HGVS coding sequence name.
+func (a Annotation) HGVSc() string {
+	return a.Get("HGVSc", "HGVS.c")
+}
+
+// HGVSp returns the HGVS protein sequence name.
+func (a Annotation) HGVSp() string {
+	return a.Get("HGVSp", "HGVS.p")
+}
+
+// CsqKeys returns the names of the fields of the VEP CSQ INFO field, from
+// its header description.
+func (v Variant) CsqKeys() ([]string, error) {
+	return v.AnnotationKeys("CSQ")
+}
+
+// AnnotationKeys returns the names of the fields of an annotation INFO
+// field such as CSQ (VEP) or ANN (SnpEff), from its header description.
+// Custom VEP --fields are read from the header, so their order does not
+// matter.
+func (v Variant) AnnotationKeys(key string) ([]string, error) {
+	if v.header == nil {
+		return nil, errors.New("variant has no header")
+	}
+	def := v.definition("INFO", key)
+	if def == nil {
+		return nil, fmt.Errorf("no %s INFO record in VCF header", key)
+	}
+	return parseAnnotationKeys(def.Get("Description")), nil
+}
+
+// parseAnnotationKeys returns the field names listed in the description of
+// an annotation INFO field, for example, "Consequence annotations from
+// Ensembl VEP. Format: Allele|Consequence|..." or "Functional annotations:
+// 'Allele | Annotation | ...'".
+func parseAnnotationKeys(s string) []string {
+	if i := strings.Index(s, "Format:"); i >= 0 {
+		s = s[i+len("Format:"):]
+	} else if i := strings.LastIndex(s, ":"); i >= 0 {
+		s = s[i+1:]
+	}
+	s = strings.Trim(s, " '\"")
+	xs := strings.Split(s, "|")
+	for i, x := range xs {
+		xs[i] = strings.TrimSpace(x)
+	}
+	return xs
+}
+
+// Annotations returns the annotations in an annotation INFO field such as
+// CSQ (VEP) or ANN (SnpEff), with the field names given by its header
+// description (see AnnotationKeys). It returns no annotations if v does
+// not have the field.
+//
+// Each annotation is mapped to its ALT allele by the VEP ALLELE_NUM field,
+// if present, otherwise by its Allele field. Except for ANN, alleles are
+// compared as written by VEP, which removes the first base of indels.
+func (v Variant) Annotations(key string) ([]Annotation, error) {
+	keys, err := v.AnnotationKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.Info[key]; !ok {
+		return nil, nil
+	}
+	entries, err := v.AttributeAsStringSlice(key)
+	if err != nil {
+		return nil, err
+	}
+	alleles := v.Alt
+	if key != "ANN" {
+		alleles = v.vepAlleles()
+	}
+	xs := make([]Annotation, 0, len(entries))
+	for i, entry := range entries {
+		values := strings.Split(entry, "|")
+		if len(values) > len(keys) {
+			return nil, v.infoError(key, fmt.Errorf("annotation %d has %d fields, but the header lists %d", i+1, len(values), len(keys)))
+		}
+		// Annotators may omit empty trailing fields.
+		a := Annotation{Fields: make(map[string]string, len(keys)), AltIndex: -1}
+		for j, k := range keys {
+			if j < len(values) {
+				a.Fields[k] = values[j]
+			} else {
+				a.Fields[k] = ""
+			}
+		}
+		if n, err := strconv.Atoi(a.Get("ALLELE_NUM")); err == nil && n > 0 && n <= len(v.Alt) {
+			a.AltIndex = n - 1
+		} else {
+			for j, allele := range alleles {
+				if allele == a.Allele() {
+					a.AltIndex = j
+					break
+				}
+			}
+		}
+		xs = append(xs, a)
+	}
+	return xs, nil
+}
+
+// vepAlleles returns the ALT alleles as written by VEP, which removes
+// the first base of every allele if REF and the ALT alleles all start with
+// the same base and any ALT allele is an indel, a different length from
+// REF, and writes "-" for the empty alleles this leaves.
+func (v Variant) vepAlleles() []string {
+	xs := make([]string, len(v.Alt))
+	trim := v.Ref != ""
+	indel := false
+	for _, alt := range v.Alt {
+		if isSymbolicAllele(alt) {
+			continue
+		}
+		trim = trim && alt != "" && alt[0] == v.Ref[0]
+		indel = indel || len(alt) != len(v.Ref)
+	}
+	trim = trim && indel
+	for i, alt := range v.Alt {
+		xs[i] = alt
+		if trim && !isSymbolicAllele(alt) {
+			xs[i] = alt[1:]
+			if xs[i] == "" {
+				xs[i] = "-"
+			}
+		}
+	}
+	return xs
+}
@@ -0,0 +1,101 @@
+package vcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+const annotationTestVCF = `##fileformat=VCFv4.2
+##INFO=<ID=CSQ,Number=.,Type=String,Description="Consequence annotations from Ensembl VEP. Format: Allele|Consequence|IMPACT|SYMBOL|Gene|Feature|HGVSc|HGVSp">
+##INFO=<ID=ANN,Number=.,Type=String,Description="Functional annotations: 'Allele | Annotation | Annotation_Impact | Gene_Name | Gene_ID | Feature_Type | Feature_ID | Transcript_BioType | Rank | HGVS.c | HGVS.p' ">
+##INFO=<ID=VEP,Number=.,Type=String,Description="Consequence annotations from Ensembl VEP. Format: ALLELE_NUM|SYMBOL|Consequence|Allele">
+##contig=<ID=1,length=249250621>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	100	.	G	A,T	.	PASS	CSQ=A|missense_variant|MODERATE|BRCA1|ENSG01|ENST01|c.10G>A|p.Val4Ile,T|stop_gained&splice_region_variant|HIGH|BRCA1|ENSG01|ENST01|c.10G>T|p.Val4Ter,T|upstream_gene_variant|MODIFIER|NBR2|ENSG02|ENST02;ANN=A|missense_variant|MODERATE|BRCA1|ENSG01|transcript|ENST01|protein_coding|2/10|c.10G>A|p.Val4Ile
+1	200	.	CAT	C,CATAT	.	PASS	CSQ=-|frameshift_variant|HIGH|TP53|ENSG03|ENST03||,ATAT|inframe_insertion|MODERATE|TP53|ENSG03|ENST03|c.5_6insAT|;ANN=C|frameshift_variant|HIGH|TP53|ENSG03|transcript|ENST03|protein_coding|1/5|c.5_6del|
+1	300	.	A	AT,AAT	.	PASS	CSQ=AT|intron_variant|MODIFIER|EGFR|ENSG04|ENST04,G|intron_variant|MODIFIER|EGFR|ENSG04|ENST04;VEP=2|EGFR|intron_variant|AT
+1	400	.	A	C	.	PASS	CSQ=C|a|b|c|d|e|f|g|h
+1	500	.	AC	AT	.	PASS	CSQ=AT|missense_variant|MODERATE|KRAS|ENSG05|ENST05
+1	600	.	AC	GC,ACT,A	.	PASS	CSQ=GC|missense_variant|MODERATE|KRAS|ENSG05|ENST05,ACT|frameshift_variant|HIGH|KRAS|ENSG05|ENST05,A|frameshift_variant|HIGH|KRAS|ENSG05|ENST05
+`
+
+func TestVariant_Annotations(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.vcf", annotationTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := scanAll(t, s)
+	type summary struct {
+		Alt          int
+		Gene         string
+		GeneID       string
+		Transcript   string
+		Consequences []string
+		Impact       string
+		HGVSc        string
+		HGVSp        string
+	}
+	tests := []struct {
+		name    string
+		v       Variant
+		key     string
+		want    []summary
+		wantErr bool
+	}{
+		{"CSQ", vs[0], "CSQ", []summary{
+			{0, "BRCA1", "ENSG01", "ENST01", []string{"missense_variant"}, "MODERATE", "c.10G>A", "p.Val4Ile"},
+			{1, "BRCA1", "ENSG01", "ENST01", []string{"stop_gained", "splice_region_variant"}, "HIGH", "c.10G>T", "p.Val4Ter"},
+			{1, "NBR2", "ENSG02", "ENST02", []string{"upstream_gene_variant"}, "MODIFIER", "", ""},
+		}, false},
+		{"ANN", vs[0], "ANN", []summary{
+			{0, "BRCA1", "ENSG01", "ENST01", []string{"missense_variant"}, "MODERATE", "c.10G>A", "p.Val4Ile"},
+		}, false},
+		{"CSQ indels", vs[1], "CSQ", []summary{
+			{0, "TP53", "ENSG03", "ENST03", []string{"frameshift_variant"}, "HIGH", "", ""},
+			{1, "TP53", "ENSG03", "ENST03", []string{"inframe_insertion"}, "MODERATE", "c.5_6insAT", ""},
+		}, false},
+		{"ANN indel", vs[1], "ANN", []summary{
+			{0, "TP53", "ENSG03", "ENST03", []string{"frameshift_variant"}, "HIGH", "c.5_6del", ""},
+		}, false},
+		{"trimmed alleles", vs[2], "CSQ", []summary{
+			{1, "EGFR", "ENSG04", "ENST04", []string{"intron_variant"}, "MODIFIER", "", ""},
+			{-1, "EGFR", "ENSG04", "ENST04", []string{"intron_variant"}, "MODIFIER", "", ""},
+		}, false},
+		{"ALLELE_NUM", vs[2], "VEP", []summary{
+			{1, "EGFR", "", "", []string{"intron_variant"}, "", "", ""},
+		}, false},
+		{"missing", vs[2], "ANN", []summary{}, false},
+		{"MNP", vs[4], "CSQ", []summary{
+			{0, "KRAS", "ENSG05", "ENST05", []string{"missense_variant"}, "MODERATE", "", ""},
+		}, false},
+		{"SNV and indels", vs[5], "CSQ", []summary{
+			{0, "KRAS", "ENSG05", "ENST05", []string{"missense_variant"}, "MODERATE", "", ""},
+			{1, "KRAS", "ENSG05", "ENST05", []string{"frameshift_variant"}, "HIGH", "", ""},
+			{2, "KRAS", "ENSG05", "ENST05", []string{"frameshift_variant"}, "HIGH", "", ""},
+		}, false},
+		{"too many fields", vs[3], "CSQ", nil, true},
+		{"undefined", vs[0], "XX", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xs, err := tt.v.Annotations(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Variant.Annotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := []summary{}
+			for _, a := range xs {
+				got = append(got, summary{a.AltIndex, a.Gene(), a.GeneID(), a.Transcript(), a.Consequences(), a.Impact(), a.HGVSc(), a.HGVSp()})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variant.Annotations() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
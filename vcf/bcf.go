@@ -0,0 +1,899 @@
+package vcf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BCF2 typed value types.
+const (
+	bcfNull  = 0
+	bcfInt8  = 1
+	bcfInt16 = 2
+	bcfInt32 = 3
+	bcfFloat = 5
+	bcfChar  = 7
+)
+
+// Sentinel values for missing values and the end of vectors shorter than
+// the declared length.
+const (
+	bcfInt8Missing   = math.MinInt8
+	bcfInt8EndOfVec  = math.MinInt8 + 1
+	bcfInt16Missing  = math.MinInt16
+	bcfInt16EndOfVec = math.MinInt16 + 1
+	bcfInt32Missing  = math.MinInt32
+	bcfInt32EndOfVec = math.MinInt32 + 1
+	bcfFloatMissing  = 0x7f800001
+	bcfFloatEndOfVec = 0x7f800002
+)
+
+// bcfMaxHeaderBytes guards against allocating huge buffers for corrupt
+// headers.
+const bcfMaxHeaderBytes = 1 << 30
+
+// bcfDict holds the string and contig dictionaries of a BCF header. Records
+// refer to FILTER, INFO and FORMAT IDs and to contigs by their index in
+// these dictionaries.
+type bcfDict struct {
+	strings []string
+	contigs []string
+}
+
+// newBCFDict builds the dictionaries of h. PASS is always the first string.
+// The IDX attribute, when present, gives the index of a string or contig
+// explicitly; otherwise IDs are numbered in the order they first appear.
+func newBCFDict(h Header) *bcfDict {
+	d := &bcfDict{strings: []string{"PASS"}}
+	seen := map[string]bool{"PASS": true}
+	for _, l := range h.lines {
+		switch l.Key {
+		case "FILTER", "INFO", "FORMAT":
+			id := l.ID()
+			if id == "" || (seen[id] && l.Get("IDX") == "") {
+				continue
+			}
+			seen[id] = true
+			d.strings = bcfDictAdd(d.strings, id, l.Get("IDX"))
+		case "contig":
+			d.contigs = bcfDictAdd(d.contigs, l.ID(), l.Get("IDX"))
+		}
+	}
+	return d
+}
+
+func bcfDictAdd(dict []string, id, idx string) []string {
+	i, err := strconv.Atoi(idx)
+	if err != nil || i < 0 {
+		return append(dict, id)
+	}
+	for len(dict) <= i {
+		dict = append(dict, "")
+	}
+	dict[i] = id
+	return dict
+}
+
+// readBCFHeader reads the magic number and header text from the start of a
+// decompressed BCF file, leaving r at the first record.
+func readBCFHeader(r io.Reader) (Header, error) {
+	var magic [5]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return Header{}, fmt.Errorf("reading BCF header failed: %w", err)
+	}
+	if string(magic[:3]) != "BCF" {
+		return Header{}, errors.New("not a BCF file")
+	}
+	if magic[3] != 2 {
+		return Header{}, fmt.Errorf("unsupported BCF version %d.%d", magic[3], magic[4])
+	}
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return Header{}, fmt.Errorf("reading BCF header failed: %w", err)
+	}
+	if n > bcfMaxHeaderBytes {
+		return Header{}, fmt.Errorf("BCF header is too large: %d bytes", n)
+	}
+	text := make([]byte, n)
+	if _, err := io.ReadFull(r, text); err != nil {
+		return Header{}, fmt.Errorf("reading BCF header failed: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(text), "\x00\n"), "\n")
+	return parseHeader(lines)
+}
+
+// bcfReader decodes BCF2 records into Variants.
+type bcfReader struct {
+	r       io.Reader
+	dict    *bcfDict
+	samples []string
+	buf     []byte
+}
+
+func newBCFReader(r io.Reader, dict *bcfDict, samples []string) *bcfReader {
+	return &bcfReader{r: r, dict: dict, samples: samples}
+}
+
+func (b *bcfReader) read() (Variant, error) {
+	var lens [8]byte
+	if _, err := io.ReadFull(b.r, lens[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Variant{}, errors.New("truncated BCF record")
+		}
+		return Variant{}, err
+	}
+	n := int(binary.LittleEndian.Uint32(lens[:])) + int(binary.LittleEndian.Uint32(lens[4:]))
+	if cap(b.buf) < n {
+		b.buf = make([]byte, n)
+	}
+	b.buf = b.buf[:n]
+	if _, err := io.ReadFull(b.r, b.buf); err != nil {
+		return Variant{}, errors.New("truncated BCF record")
+	}
+	d := &bcfDecoder{buf: b.buf}
+	v, err := d.record(b.dict, b.samples)
+	if err != nil {
+		return Variant{}, fmt.Errorf("unable to decode BCF record: %w", err)
+	}
+	return v, nil
+}
+
+// bcfDecoder decodes the typed values in a single BCF record.
+type bcfDecoder struct {
+	buf []byte
+	off int
+}
+
+var errBCFTruncated = errors.New("record is truncated")
+
+func (d *bcfDecoder) next(n int) ([]byte, error) {
+	if n < 0 || d.off+n > len(d.buf) {
+		return nil, errBCFTruncated
+	}
+	bs := d.buf[d.off : d.off+n]
+	d.off += n
+	return bs, nil
+}
+
+func (d *bcfDecoder) uint32() (uint32, error) {
+	bs, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(bs), nil
+}
+
+// descriptor reads a type descriptor byte, and the following length if it
+// does not fit in the descriptor.
+func (d *bcfDecoder) descriptor() (typ byte, n int, err error) {
+	bs, err := d.next(1)
+	if err != nil {
+		return 0, 0, err
+	}
+	typ, n = bs[0]&0x0f, int(bs[0]>>4)
+	if n == 15 {
+		xs, err := d.ints()
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(xs) != 1 || xs[0] < 0 {
+			return 0, 0, errors.New("invalid typed value length")
+		}
+		n = xs[0]
+	}
+	return typ, n, nil
+}
+
+func bcfTypeSize(typ byte) (int, error) {
+	switch typ {
+	case bcfNull:
+		return 0, nil
+	case bcfInt8, bcfChar:
+		return 1, nil
+	case bcfInt16:
+		return 2, nil
+	case bcfInt32, bcfFloat:
+		return 4, nil
+	}
+	return 0, fmt.Errorf("unknown type %d", typ)
+}
+
+// ints reads a typed vector of integers. Missing values are returned as
+// bcfInt32Missing and the vector is truncated at the first end of vector
+// value.
+func (d *bcfDecoder) ints() ([]int, error) {
+	typ, n, err := d.descriptor()
+	if err != nil {
+		return nil, err
+	}
+	return d.intValues(typ, n)
+}
+
+func (d *bcfDecoder) intValues(typ byte, n int) ([]int, error) {
+	size, err := bcfTypeSize(typ)
+	if err != nil {
+		return nil, err
+	}
+	if typ == bcfFloat || typ == bcfChar {
+		return nil, fmt.Errorf("expected integers, found type %d", typ)
+	}
+	bs, err := d.next(size * n)
+	if err != nil {
+		return nil, err
+	}
+	xs := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		var x, missing, eov int
+		switch typ {
+		case bcfInt8:
+			x, missing, eov = int(int8(bs[i])), bcfInt8Missing, bcfInt8EndOfVec
+		case bcfInt16:
+			x, missing, eov = int(int16(binary.LittleEndian.Uint16(bs[2*i:]))), bcfInt16Missing, bcfInt16EndOfVec
+		case bcfInt32:
+			x, missing, eov = int(int32(binary.LittleEndian.Uint32(bs[4*i:]))), bcfInt32Missing, bcfInt32EndOfVec
+		}
+		if x == eov {
+			break
+		}
+		if x == missing {
+			x = bcfInt32Missing
+		}
+		xs = append(xs, x)
+	}
+	return xs, nil
+}
+
+// string reads a typed character vector.
+func (d *bcfDecoder) string() (string, error) {
+	typ, n, err := d.descriptor()
+	if err != nil {
+		return "", err
+	}
+	if typ != bcfChar && !(typ == bcfNull && n == 0) {
+		return "", fmt.Errorf("expected a string, found type %d", typ)
+	}
+	bs, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+	return bcfString(bs), nil
+}
+
+// bcfString returns the characters before any NUL padding.
+func bcfString(bs []byte) string {
+	if i := bytes.IndexByte(bs, 0); i >= 0 {
+		bs = bs[:i]
+	}
+	return string(bs)
+}
+
+// values reads n values of type typ and formats them as they would appear
+// in a VCF: comma separated, with "." for missing values.
+func (d *bcfDecoder) values(typ byte, n int) (string, error) {
+	switch typ {
+	case bcfNull:
+		return "", nil
+	case bcfChar:
+		bs, err := d.next(n)
+		if err != nil {
+			return "", err
+		}
+		return bcfString(bs), nil
+	case bcfFloat:
+		bs, err := d.next(4 * n)
+		if err != nil {
+			return "", err
+		}
+		xs := []string{}
+		for i := 0; i < n; i++ {
+			bits := binary.LittleEndian.Uint32(bs[4*i:])
+			if bits == bcfFloatEndOfVec {
+				break
+			}
+			xs = append(xs, formatBCFFloat(bits))
+		}
+		return strings.Join(xs, ","), nil
+	}
+	ints, err := d.intValues(typ, n)
+	if err != nil {
+		return "", err
+	}
+	xs := make([]string, len(ints))
+	for i, x := range ints {
+		xs[i] = "."
+		if x != bcfInt32Missing {
+			xs[i] = strconv.Itoa(x)
+		}
+	}
+	return strings.Join(xs, ","), nil
+}
+
+func formatBCFFloat(bits uint32) string {
+	if bits == bcfFloatMissing {
+		return "."
+	}
+	return strconv.FormatFloat(float64(math.Float32frombits(bits)), 'g', -1, 32)
+}
+
+// genotype reads n GT values and formats them as a VCF genotype. Each value
+// is the allele index plus one, shifted left by one, with the low bit set if
+// the allele is phased with the previous one.
+func (d *bcfDecoder) genotype(typ byte, n int) (string, error) {
+	xs, err := d.intValues(typ, n)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	// The phasing of the first allele is written explicitly if it can not
+	// be inferred from the others (see Genotype.IsAllelePhased).
+	if len(xs) > 0 && xs[0] != bcfInt32Missing && xs[0]&1 == 1 {
+		inferred := len(xs) > 1
+		for _, x := range xs[1:] {
+			inferred = inferred && x&1 == 1
+		}
+		if !inferred {
+			b.WriteByte('|')
+		}
+	}
+	for i, x := range xs {
+		if i > 0 {
+			if x&1 == 1 {
+				b.WriteByte('|')
+			} else {
+				b.WriteByte('/')
+			}
+		}
+		if x == bcfInt32Missing || x>>1 == 0 {
+			b.WriteByte('.')
+		} else {
+			b.WriteString(strconv.Itoa(x>>1 - 1))
+		}
+	}
+	if b.Len() == 0 {
+		return ".", nil
+	}
+	return b.String(), nil
+}
+
+func (d *bcfDecoder) lookup(dict []string, what string) (string, error) {
+	xs, err := d.ints()
+	if err != nil {
+		return "", err
+	}
+	if len(xs) != 1 || xs[0] < 0 || xs[0] >= len(dict) || dict[xs[0]] == "" {
+		return "", fmt.Errorf("invalid %s index %v", what, xs)
+	}
+	return dict[xs[0]], nil
+}
+
+// record decodes the shared and per-sample parts of a record.
+func (d *bcfDecoder) record(dict *bcfDict, samples []string) (Variant, error) {
+	var fixed [6]uint32
+	for i := range fixed {
+		x, err := d.uint32()
+		if err != nil {
+			return Variant{}, err
+		}
+		fixed[i] = x
+	}
+	rid := int(int32(fixed[0]))
+	if rid < 0 || rid >= len(dict.contigs) || dict.contigs[rid] == "" {
+		return Variant{}, fmt.Errorf("invalid contig index %d", rid)
+	}
+	v := Variant{
+		Chrom:  dict.contigs[rid],
+		Pos:    int(int32(fixed[1])) + 1,
+		Qual:   formatBCFFloat(fixed[3]),
+		Filter: []string{},
+		Info:   make(map[string]string),
+	}
+	nInfo, nAllele := int(fixed[4]&0xffff), int(fixed[4]>>16)
+	nSample, nFormat := int(fixed[5]&0xffffff), int(fixed[5]>>24)
+	var err error
+	if v.ID, err = d.string(); err != nil {
+		return Variant{}, err
+	}
+	if v.ID == "" {
+		v.ID = "."
+	}
+	alleles := make([]string, nAllele)
+	for i := range alleles {
+		if alleles[i], err = d.string(); err != nil {
+			return Variant{}, err
+		}
+	}
+	if len(alleles) > 0 {
+		v.Ref = alleles[0]
+	}
+	v.Alt = []string{"."}
+	if len(alleles) > 1 {
+		v.Alt = alleles[1:]
+	}
+	filters, err := d.ints()
+	if err != nil {
+		return Variant{}, err
+	}
+	for _, i := range filters {
+		if i < 0 || i >= len(dict.strings) {
+			return Variant{}, fmt.Errorf("invalid FILTER index %d", i)
+		}
+		// The text parser does not keep PASS either.
+		if i != 0 {
+			v.Filter = append(v.Filter, dict.strings[i])
+		}
+	}
+	for i := 0; i < nInfo; i++ {
+		key, err := d.lookup(dict.strings, "INFO")
+		if err != nil {
+			return Variant{}, err
+		}
+		typ, n, err := d.descriptor()
+		if err != nil {
+			return Variant{}, err
+		}
+		value, err := d.values(typ, n)
+		if err != nil {
+			return Variant{}, fmt.Errorf("INFO %s: %w", key, err)
+		}
+		if typ == bcfNull || value == "" {
+			// A flag, which the text parser also stores as 1.
+			value = "1"
+		} else if value == "1" {
+			v.explicitOnes = append(v.explicitOnes, key)
+		}
+		if _, ok := v.Info[key]; !ok {
+			v.infoKeys = append(v.infoKeys, key)
+		}
+		v.Info[key] = value
+	}
+	if nSample != len(samples) && nFormat > 0 {
+		return Variant{}, fmt.Errorf("record has %d samples but the header has %d", nSample, len(samples))
+	}
+	values := make([]map[string]string, nSample)
+	for i := range values {
+		values[i] = make(map[string]string)
+	}
+	for i := 0; i < nFormat; i++ {
+		key, err := d.lookup(dict.strings, "FORMAT")
+		if err != nil {
+			return Variant{}, err
+		}
+		typ, n, err := d.descriptor()
+		if err != nil {
+			return Variant{}, err
+		}
+		v.Format = append(v.Format, key)
+		for j := range values {
+			var value string
+			if key == "GT" {
+				value, err = d.genotype(typ, n)
+			} else {
+				value, err = d.values(typ, n)
+			}
+			if err != nil {
+				return Variant{}, fmt.Errorf("FORMAT %s: %w", key, err)
+			}
+			if value == "" {
+				value = "."
+			}
+			values[j][key] = value
+		}
+	}
+	if nFormat == 0 {
+		return v, nil
+	}
+	for i, vs := range values {
+		g, err := NewGenotype(samples[i], vs)
+		if err != nil {
+			return Variant{}, fmt.Errorf("unable to create genotype: %w", err)
+		}
+		if err := v.AddGenotype(g); err != nil {
+			return Variant{}, err
+		}
+	}
+	return v, nil
+}
+
+// bcfEncoder encodes Variants as BCF2 records, typing INFO and FORMAT values
+// according to their header definitions.
+type bcfEncoder struct {
+	dict    *bcfDict
+	samples []string
+	strings map[string]int
+	contigs map[string]int
+	types   map[string]string
+	header  *Header
+	buf     bytes.Buffer
+}
+
+// newBCFEncoder creates an encoder for records described by h. The header
+// lines must be in the order they are written to the file, as the
+// dictionaries are built from that order.
+func newBCFEncoder(h Header) *bcfEncoder {
+	e := &bcfEncoder{
+		dict:    newBCFDict(h),
+		samples: h.Samples,
+		strings: make(map[string]int),
+		contigs: make(map[string]int),
+		types:   make(map[string]string),
+		header:  &h,
+	}
+	for i, s := range e.dict.strings {
+		e.strings[s] = i
+	}
+	for i, c := range e.dict.contigs {
+		e.contigs[c] = i
+	}
+	for _, l := range h.lines {
+		if l.Key == "INFO" || l.Key == "FORMAT" {
+			e.types[l.Key+"/"+l.ID()] = l.Get("Type")
+		}
+	}
+	return e
+}
+
+// encode returns the BCF record for v, including the leading record
+// lengths.
+func (e *bcfEncoder) encode(v Variant) ([]byte, error) {
+	rid, ok := e.contigs[v.Chrom]
+	if !ok {
+		return nil, fmt.Errorf("contig %s is not defined in the header", v.Chrom)
+	}
+	qual := uint32(bcfFloatMissing)
+	if v.Qual != "" && v.Qual != "." {
+		q, err := strconv.ParseFloat(v.Qual, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid QUAL %s", v.Chrom, v.Pos, v.Qual)
+		}
+		qual = math.Float32bits(float32(q))
+	}
+	alleles := []string{v.Ref}
+	if len(v.Alt) != 1 || v.Alt[0] != "." {
+		alleles = append(alleles, v.Alt...)
+	}
+	info := make([]string, 0, len(v.Info))
+	for k := range v.Info {
+		info = append(info, k)
+	}
+	// Use the dictionary order so that the encoding is deterministic.
+	sort.Slice(info, func(i, j int) bool { return e.strings[info[i]] < e.strings[info[j]] })
+	nFormat := len(v.Format)
+	if len(v.genotypes) == 0 {
+		nFormat = 0
+	}
+	if err := e.checkNumbers(v); err != nil {
+		return nil, err
+	}
+
+	e.buf.Reset()
+	le := binary.LittleEndian
+	binary.Write(&e.buf, le, [2]uint32{})
+	binary.Write(&e.buf, le, [3]int32{int32(rid), int32(v.Start0()), int32(v.End0() - v.Start0())})
+	binary.Write(&e.buf, le, qual)
+	binary.Write(&e.buf, le, uint32(len(alleles)<<16|len(info)))
+	binary.Write(&e.buf, le, uint32(nFormat<<24|len(e.samples)))
+	id := v.ID
+	if id == "." {
+		id = ""
+	}
+	e.string(id)
+	for _, a := range alleles {
+		e.string(a)
+	}
+	filters := []int{}
+	for _, f := range v.Filter {
+		i, ok := e.strings[f]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: FILTER %s is not defined in the header", v.Chrom, v.Pos, f)
+		}
+		filters = append(filters, i)
+	}
+	e.ints(filters)
+	for _, k := range info {
+		if err := e.info(k, v.Info[k]); err != nil {
+			return nil, fmt.Errorf("%s:%d: INFO %s: %w", v.Chrom, v.Pos, k, err)
+		}
+	}
+	shared := e.buf.Len() - 8
+	if nFormat > 0 {
+		if len(v.genotypes) != len(e.samples) {
+			return nil, fmt.Errorf("%s:%d: variant has %d samples but the header has %d", v.Chrom, v.Pos, len(v.genotypes), len(e.samples))
+		}
+		for _, k := range v.Format {
+			values := make([]string, len(v.genotypes))
+			for i, g := range v.genotypes {
+				values[i] = g.values[k]
+			}
+			if err := e.format(k, values); err != nil {
+				return nil, fmt.Errorf("%s:%d: FORMAT %s: %w", v.Chrom, v.Pos, k, err)
+			}
+		}
+	}
+	bs := e.buf.Bytes()
+	le.PutUint32(bs, uint32(shared))
+	le.PutUint32(bs[4:], uint32(len(bs)-8-shared))
+	return bs, nil
+}
+
+func (e *bcfEncoder) key(k string) error {
+	i, ok := e.strings[k]
+	if !ok {
+		return errors.New("not defined in the header")
+	}
+	e.ints([]int{i})
+	return nil
+}
+
+// checkNumbers returns an error if an Integer or Float INFO or FORMAT field
+// of v has a different number of values than its header Number requires.
+// htslib can not read such records; string values are not checked, as it
+// does not split them.
+func (e *bcfEncoder) checkNumbers(v Variant) error {
+	v.header = e.header
+	numeric := func(key string) bool {
+		return e.types[key] == "Integer" || e.types[key] == "Float"
+	}
+	for k := range v.Info {
+		if !numeric("INFO/" + k) {
+			continue
+		}
+		if _, _, err := v.infoValues(k); err != nil {
+			return err
+		}
+	}
+	if len(v.genotypes) == 0 {
+		return nil
+	}
+	for _, k := range v.Format {
+		if k == "GT" || !numeric("FORMAT/"+k) {
+			continue
+		}
+		for _, g := range v.genotypes {
+			g.v = &v
+			if _, _, err := g.formatValues(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *bcfEncoder) info(k, value string) error {
+	if err := e.key(k); err != nil {
+		return err
+	}
+	switch e.types["INFO/"+k] {
+	case "Flag":
+		e.descriptor(bcfNull, 0)
+	case "Integer":
+		xs, err := parseBCFInts(value)
+		if err != nil {
+			return err
+		}
+		e.ints(xs)
+	case "Float":
+		xs, err := parseBCFFloats(value)
+		if err != nil {
+			return err
+		}
+		e.floats(xs)
+	case "String", "Character":
+		e.string(infoEscaper.Replace(value))
+	default:
+		return fmt.Errorf("unknown type %q", e.types["INFO/"+k])
+	}
+	return nil
+}
+
+// format encodes the values of a FORMAT field for every sample. Vectors
+// shorter than the longest are padded with end of vector values.
+func (e *bcfEncoder) format(k string, values []string) error {
+	if err := e.key(k); err != nil {
+		return err
+	}
+	typ := e.types["FORMAT/"+k]
+	if k == "GT" {
+		typ = "GT"
+	}
+	switch typ {
+	case "GT", "Integer":
+		parse := parseBCFInts
+		if typ == "GT" {
+			parse = parseBCFGenotype
+		}
+		vectors := make([][]int, len(values))
+		width := 0
+		for i, s := range values {
+			xs, err := parse(s)
+			if err != nil {
+				return err
+			}
+			vectors[i] = xs
+			if len(xs) > width {
+				width = len(xs)
+			}
+		}
+		all := make([]int, 0, width*len(values))
+		for _, xs := range vectors {
+			all = append(all, xs...)
+			for i := len(xs); i < width; i++ {
+				all = append(all, bcfInt32EndOfVec)
+			}
+		}
+		t := bcfIntType(all)
+		e.descriptor(t, width)
+		e.intValues(t, all)
+	case "Float":
+		vectors := make([][]uint32, len(values))
+		width := 0
+		for i, s := range values {
+			xs, err := parseBCFFloats(s)
+			if err != nil {
+				return err
+			}
+			vectors[i] = xs
+			if len(xs) > width {
+				width = len(xs)
+			}
+		}
+		e.descriptor(bcfFloat, width)
+		for _, xs := range vectors {
+			for i := len(xs); i < width; i++ {
+				xs = append(xs, bcfFloatEndOfVec)
+			}
+			binary.Write(&e.buf, binary.LittleEndian, xs)
+		}
+	case "String", "Character":
+		for i, s := range values {
+			values[i] = formatEscaper.Replace(s)
+		}
+		width := 0
+		for _, s := range values {
+			if len(s) > width {
+				width = len(s)
+			}
+		}
+		e.descriptor(bcfChar, width)
+		for _, s := range values {
+			e.buf.WriteString(s)
+			for i := len(s); i < width; i++ {
+				e.buf.WriteByte(0)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q", typ)
+	}
+	return nil
+}
+
+func (e *bcfEncoder) descriptor(typ byte, n int) {
+	if n < 15 {
+		e.buf.WriteByte(byte(n)<<4 | typ)
+		return
+	}
+	e.buf.WriteByte(15<<4 | typ)
+	e.ints([]int{n})
+}
+
+func (e *bcfEncoder) string(s string) {
+	e.descriptor(bcfChar, len(s))
+	e.buf.WriteString(s)
+}
+
+func (e *bcfEncoder) ints(xs []int) {
+	typ := bcfIntType(xs)
+	e.descriptor(typ, len(xs))
+	e.intValues(typ, xs)
+}
+
+// intValues writes xs as integers of type typ, translating the missing and
+// end of vector values to those of the type.
+func (e *bcfEncoder) intValues(typ byte, xs []int) {
+	for _, x := range xs {
+		switch typ {
+		case bcfInt8:
+			switch x {
+			case bcfInt32Missing:
+				x = bcfInt8Missing
+			case bcfInt32EndOfVec:
+				x = bcfInt8EndOfVec
+			}
+			e.buf.WriteByte(byte(int8(x)))
+		case bcfInt16:
+			switch x {
+			case bcfInt32Missing:
+				x = bcfInt16Missing
+			case bcfInt32EndOfVec:
+				x = bcfInt16EndOfVec
+			}
+			binary.Write(&e.buf, binary.LittleEndian, int16(x))
+		default:
+			binary.Write(&e.buf, binary.LittleEndian, int32(x))
+		}
+	}
+}
+
+func (e *bcfEncoder) floats(xs []uint32) {
+	e.descriptor(bcfFloat, len(xs))
+	binary.Write(&e.buf, binary.LittleEndian, xs)
+}
+
+// bcfIntType returns the smallest integer type that can hold xs. The lowest
+// eight values of each type are reserved for missing and end of vector
+// values.
+func bcfIntType(xs []int) byte {
+	typ := byte(bcfInt8)
+	for _, x := range xs {
+		if x == bcfInt32Missing || x == bcfInt32EndOfVec {
+			continue
+		}
+		switch {
+		case x < math.MinInt16+8 || x > math.MaxInt16:
+			return bcfInt32
+		case x < math.MinInt8+8 || x > math.MaxInt8:
+			typ = bcfInt16
+		}
+	}
+	return typ
+}
+
+// parseBCFInts parses comma separated integers, returning bcfInt32Missing
+// for "." values.
+func parseBCFInts(s string) ([]int, error) {
+	bits := strings.Split(s, ",")
+	xs := make([]int, len(bits))
+	for i, b := range bits {
+		if b == "." {
+			xs[i] = bcfInt32Missing
+			continue
+		}
+		x, err := strconv.ParseInt(b, 10, 32)
+		if err != nil || x < math.MinInt32+8 {
+			return nil, fmt.Errorf("invalid Integer value %q", b)
+		}
+		xs[i] = int(x)
+	}
+	return xs, nil
+}
+
+// parseBCFFloats parses comma separated floats, returning the bits of each
+// as a float32 and bcfFloatMissing for "." values.
+func parseBCFFloats(s string) ([]uint32, error) {
+	bits := strings.Split(s, ",")
+	xs := make([]uint32, len(bits))
+	for i, b := range bits {
+		if b == "." {
+			xs[i] = bcfFloatMissing
+			continue
+		}
+		f, err := strconv.ParseFloat(b, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Float value %q", b)
+		}
+		xs[i] = math.Float32bits(float32(f))
+	}
+	return xs, nil
+}
+
+// parseBCFGenotype parses a GT value into the BCF encoding of its alleles.
+func parseBCFGenotype(gt string) ([]int, error) {
+	alleles, phasing, err := parseGT(gt)
+	if err != nil {
+		return nil, err
+	}
+	xs := make([]int, len(alleles))
+	for i, a := range alleles {
+		xs[i] = (a + 1) << 1
+		if phasing[i] {
+			xs[i] |= 1
+		}
+	}
+	return xs, nil
+}
@@ -0,0 +1,298 @@
+package vcf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/biogo/hts/bgzf"
+)
+
+const bcfTestVCF = `##fileformat=VCFv4.2
+##FILTER=<ID=PASS,Description="All filters passed">
+##FILTER=<ID=LowQual,Description="Low quality">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele Frequency">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP membership">
+##INFO=<ID=GENE,Number=1,Type=String,Description="Gene">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##contig=<ID=1,length=249250621>
+##contig=<ID=2,length=243199373>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	rs1	A	C	50	PASS	DP=20;AF=0.5;DB	GT:AD	0/1:10,10	0|0:20,0
+1	200	rs123456789012345678	AT	A	.	LowQual	DP=300;GENE=BRCA2	GT:AD	./.:.	1:3,.
+2	50	.	G	T,C	99.5	PASS	AF=0.25,0.125	GT:AD	1/2:0,20,20	0/0:40,0,0
+`
+
+// bcfTestBuffer assembles the typed values of hand written BCF records.
+type bcfTestBuffer struct {
+	bytes.Buffer
+}
+
+// typed writes a type descriptor for n values of type typ followed by the
+// values themselves.
+func (b *bcfTestBuffer) typed(typ byte, n int, values ...interface{}) {
+	if n < 15 {
+		b.WriteByte(byte(n)<<4 | typ)
+	} else {
+		b.WriteByte(15<<4 | typ)
+		b.typed(bcfInt8, 1, int8(n))
+	}
+	for _, v := range values {
+		binary.Write(b, binary.LittleEndian, v)
+	}
+}
+
+func (b *bcfTestBuffer) str(s string) {
+	b.typed(bcfChar, len(s), []byte(s))
+}
+
+// bcfTestRecord returns a record with the fixed fields given, followed by
+// the typed values in shared and indiv.
+func bcfTestRecord(rid, pos int32, qual uint32, nAllele, nInfo, nFormat int, shared, indiv []byte) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&b, le, uint32(24+len(shared)))
+	binary.Write(&b, le, uint32(len(indiv)))
+	binary.Write(&b, le, []int32{rid, pos, 1})
+	binary.Write(&b, le, qual)
+	binary.Write(&b, le, uint32(nAllele<<16|nInfo))
+	binary.Write(&b, le, uint32(nFormat<<24|2))
+	b.Write(shared)
+	b.Write(indiv)
+	return b.Bytes()
+}
+
+// bcfTestFile returns the BCF encoding of bcfTestVCF. The string dictionary
+// is PASS, LowQual, DP, AF, DB, GENE, GT and AD.
+func bcfTestFile() []byte {
+	const (
+		missing8 = int8(bcfInt8Missing)
+		eov8     = int8(bcfInt8EndOfVec)
+	)
+	var b bytes.Buffer
+	text := bcfTestVCF[:strings.Index(bcfTestVCF, "\n1\t")+1] + "\x00"
+	b.WriteString("BCF\x02\x02")
+	binary.Write(&b, binary.LittleEndian, uint32(len(text)))
+	b.WriteString(text)
+
+	var s, i bcfTestBuffer
+	s.str("rs1")
+	s.str("A")
+	s.str("C")
+	s.typed(bcfInt8, 1, int8(0))
+	s.typed(bcfInt8, 1, int8(2))
+	s.typed(bcfInt8, 1, int8(20))
+	s.typed(bcfInt8, 1, int8(3))
+	s.typed(bcfFloat, 1, float32(0.5))
+	s.typed(bcfInt8, 1, int8(4))
+	s.typed(bcfNull, 0)
+	i.typed(bcfInt8, 1, int8(6))
+	i.typed(bcfInt8, 2, []int8{2, 4, 2, 3})
+	i.typed(bcfInt8, 1, int8(7))
+	i.typed(bcfInt8, 2, []int8{10, 10, 20, 0})
+	b.Write(bcfTestRecord(0, 99, math.Float32bits(50), 2, 3, 2, s.Bytes(), i.Bytes()))
+
+	s.Reset()
+	i.Reset()
+	s.str("rs123456789012345678")
+	s.str("AT")
+	s.str("A")
+	s.typed(bcfInt8, 1, int8(1))
+	s.typed(bcfInt8, 1, int8(2))
+	s.typed(bcfInt16, 1, int16(300))
+	s.typed(bcfInt8, 1, int8(5))
+	s.str("BRCA2")
+	i.typed(bcfInt8, 1, int8(6))
+	i.typed(bcfInt8, 2, []int8{0, 0, 4, eov8})
+	i.typed(bcfInt8, 1, int8(7))
+	i.typed(bcfInt8, 2, []int8{missing8, eov8, 3, missing8})
+	b.Write(bcfTestRecord(0, 199, bcfFloatMissing, 2, 2, 2, s.Bytes(), i.Bytes()))
+
+	s.Reset()
+	i.Reset()
+	s.typed(bcfChar, 0)
+	s.str("G")
+	s.str("T")
+	s.str("C")
+	s.typed(bcfInt8, 1, int8(0))
+	s.typed(bcfInt8, 1, int8(3))
+	s.typed(bcfFloat, 2, []float32{0.25, 0.125})
+	i.typed(bcfInt8, 1, int8(6))
+	i.typed(bcfInt8, 2, []int8{4, 6, 2, 2})
+	i.typed(bcfInt8, 1, int8(7))
+	i.typed(bcfInt8, 3, []int8{0, 20, 20, 40, 0, 0})
+	b.Write(bcfTestRecord(1, 49, math.Float32bits(99.5), 3, 1, 2, s.Bytes(), i.Bytes()))
+	return b.Bytes()
+}
+
+// writeBGZF writes bs BGZF compressed to a file called name in a temporary
+// directory.
+func writeBGZF(t *testing.T, name string, bs []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bg := bgzf.NewWriter(f, 1)
+	if _, err := bg.Write(bs); err != nil {
+		t.Fatal(err)
+	}
+	if err := bg.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// decodedFields returns the parts of v that are decoded from the file.
+func decodedFields(v Variant) []interface{} {
+	xs := []interface{}{v.Chrom, v.Pos, v.ID, v.Ref, v.Alt, v.Qual, v.Filter, v.Info, v.Format}
+	for _, g := range v.Genotypes() {
+		xs = append(xs, g.Name, g.values, g.alleleIndexes, g.phased)
+	}
+	return xs
+}
+
+func TestNewScanner_bcf(t *testing.T) {
+	text, err := NewReader(strings.NewReader(bcfTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := scanAll(t, text)
+	path := writeBGZF(t, "test.bcf", bcfTestFile())
+	v, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got, want := v.Header.Samples, []string{"S1", "S2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Header.Samples = %v, want %v", got, want)
+	}
+	s, err := NewScanner(v)
+	if err != nil {
+		t.Fatalf("NewScanner() error = %v", err)
+	}
+	got := scanAll(t, s)
+	if len(got) != len(want) {
+		t.Fatalf("got %d variants, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if g, w := decodedFields(got[i]), decodedFields(want[i]); !reflect.DeepEqual(g, w) {
+			t.Errorf("variant %d = %v, want %v", i, g, w)
+		}
+	}
+	s, err = NewScanner(v, "1:150-250")
+	if err != nil {
+		t.Fatalf("NewScanner() error = %v", err)
+	}
+	if got := scanAll(t, s); len(got) != 1 || got[0].Pos != 200 {
+		t.Errorf("region 1:150-250 returned %d variants", len(got))
+	}
+}
+
+func TestNewReader_bcf(t *testing.T) {
+	bs := bcfTestFile()
+	tests := []struct {
+		name    string
+		bs      []byte
+		want    int
+		wantErr bool
+	}{
+		{"bcf", bs, 3, false},
+		{"truncated header", bs[:20], 0, true},
+		{"bad version", append([]byte("BCF\x01\x01"), bs[5:]...), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewReader(bytes.NewReader(tt.bs))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := len(scanAll(t, s)); got != tt.want {
+				t.Errorf("got %d variants, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReader_truncatedBCF(t *testing.T) {
+	bs := bcfTestFile()
+	s, err := NewReader(bytes.NewReader(bs[:len(bs)-4]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s.Scan() {
+	}
+	if s.Err() == nil {
+		t.Error("Scanner.Err() = nil, want error for truncated record")
+	}
+}
+
+func TestNewBCFDict(t *testing.T) {
+	h, err := parseHeader([]string{
+		"##fileformat=VCFv4.2",
+		`##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth">`,
+		`##FILTER=<ID=q10,Description="Low quality">`,
+		`##FILTER=<ID=PASS,Description="All filters passed">`,
+		`##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">`,
+		`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype",IDX=5>`,
+		"##contig=<ID=chr2,IDX=1>",
+		"##contig=<ID=chr1,IDX=0>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newBCFDict(h)
+	if want := []string{"PASS", "DP", "q10", "", "", "GT"}; !reflect.DeepEqual(d.strings, want) {
+		t.Errorf("strings = %q, want %q", d.strings, want)
+	}
+	if want := []string{"chr1", "chr2"}; !reflect.DeepEqual(d.contigs, want) {
+		t.Errorf("contigs = %v, want %v", d.contigs, want)
+	}
+}
+
+func Test_bcfGenotype(t *testing.T) {
+	tests := []struct {
+		gt   string
+		want string
+	}{
+		{"0/1", "0/1"},
+		{"0|1", "0|1"},
+		{"|0/1", "|0/1"},
+		{"1|0|2", "1|0|2"},
+		{"0/1|2", "0/1|2"},
+		{"|1", "|1"},
+		{"1", "1"},
+		{"./.", "./."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.gt, func(t *testing.T) {
+			xs, err := parseBCFGenotype(tt.gt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, len(xs))
+			for i, x := range xs {
+				buf[i] = byte(int8(x))
+			}
+			d := &bcfDecoder{buf: buf}
+			got, err := d.genotype(bcfInt8, len(xs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("bcfDecoder.genotype() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
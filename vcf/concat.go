@@ -0,0 +1,103 @@
+package vcf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConcatOption configures Concat.
+type ConcatOption func(*concatConfig)
+
+type concatConfig struct {
+	removeDuplicates bool
+}
+
+// RemoveDuplicates drops records with the same position and alleles as a
+// record that has already been written, for example, records repeated in
+// the overlap of two shards. Records in the overlap that are not
+// duplicates are still reported as being out of order.
+func RemoveDuplicates() ConcatOption {
+	return func(c *concatConfig) {
+		c.removeDuplicates = true
+	}
+}
+
+// Concat writes the header and variants of vs, in turn, to w, in the same
+// way as `bcftools concat`. The inputs must have the same samples, in the
+// same order, and compatible headers (see Header.Merge); the header written
+// is the union of their headers. Variants must be sorted across the inputs,
+// for example, VCFs of consecutive regions or of each chromosome in turn.
+// Concat does not close w.
+func Concat(w *Writer, vs []VCF, opts ...ConcatOption) error {
+	if len(vs) == 0 {
+		return errors.New("no VCFs to concatenate")
+	}
+	c := &concatConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	h := Header{Samples: vs[0].Header.Samples}
+	for _, v := range vs {
+		if err := h.Merge(v.Header, IdenticalSamples); err != nil {
+			return fmt.Errorf("unable to merge header of %s: %w", v.file, err)
+		}
+	}
+	if err := w.WriteHeader(h); err != nil {
+		return fmt.Errorf("unable to write header: %w", err)
+	}
+	cc := &concatenator{w: w, order: newContigOrder(h), config: c}
+	for _, v := range vs {
+		if err := cc.add(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// concatenator writes the variants of each input in turn, checking they are
+// in order.
+type concatenator struct {
+	w      *Writer
+	order  contigOrder
+	config *concatConfig
+	last   *Variant
+	// written holds the alleles of the variants written on the contig of
+	// last, by position, to find duplicates.
+	written map[int][]string
+}
+
+func (c *concatenator) add(v VCF) error {
+	s, err := NewScanner(v)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	for s.Scan() {
+		x := s.Variant()
+		if c.last != nil && c.last.Chrom != x.Chrom {
+			c.written = nil
+		}
+		alleles := strings.Join(x.Alleles(), ",")
+		if c.config.removeDuplicates && stringSliceContains(c.written[x.Pos], alleles) {
+			continue
+		}
+		if c.last != nil && c.order.less(x, *c.last) {
+			return fmt.Errorf("%s:%d in %s is before %s:%d", x.Chrom, x.Pos, v.file, c.last.Chrom, c.last.Pos)
+		}
+		if err := c.w.WriteVariant(x); err != nil {
+			return fmt.Errorf("unable to write %s:%d: %w", x.Chrom, x.Pos, err)
+		}
+		if c.config.removeDuplicates {
+			if c.written == nil {
+				c.written = make(map[int][]string)
+			}
+			c.written[x.Pos] = append(c.written[x.Pos], alleles)
+		}
+		c.last = &x
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("unable to read %s: %w", v.file, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package vcf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const concatTestHeader = `##fileformat=VCFv4.2
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##contig=<ID=1,length=1000>
+##contig=<ID=2,length=1000>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+`
+
+var concatTestShards = []string{
+	concatTestHeader + "1\t100\t.\tA\tC\t.\tPASS\tDP=1\tGT\t0/1\t0/0\n" +
+		"1\t200\t.\tA\tC\t.\tPASS\tDP=2\tGT\t0/1\t0/0\n",
+	concatTestHeader + "1\t200\t.\tA\tC\t.\tPASS\tDP=2\tGT\t0/1\t0/0\n" +
+		"1\t300\t.\tA\tC\t.\tPASS\tDP=3\tGT\t0/1\t0/0\n",
+	concatTestHeader + "2\t50\t.\tA\tC\t.\tPASS\tDP=4\tGT\t0/1\t0/0\n",
+}
+
+func concatTestVCFs(t *testing.T, shards ...string) []VCF {
+	t.Helper()
+	vs := []VCF{}
+	for i, content := range shards {
+		v, err := New(writeTestFile(t, string(rune('a'+i))+".vcf.gz", content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+func TestConcat(t *testing.T) {
+	tests := []struct {
+		name    string
+		shards  []string
+		opts    []ConcatOption
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "remove duplicates",
+			shards: concatTestShards,
+			opts:   []ConcatOption{RemoveDuplicates()},
+			want:   []string{"1:100", "1:200", "1:300", "2:50"},
+		},
+		{
+			name:   "keep duplicates",
+			shards: concatTestShards,
+			want:   []string{"1:100", "1:200", "1:200", "1:300", "2:50"},
+		},
+		{
+			name:    "overlap",
+			shards:  []string{concatTestShards[0], strings.Replace(concatTestShards[1], "1\t200", "1\t150", 1)},
+			opts:    []ConcatOption{RemoveDuplicates()},
+			wantErr: "1:150 in",
+		},
+		{
+			name:   "no overlap",
+			shards: []string{concatTestShards[0], concatTestShards[2]},
+			want:   []string{"1:100", "1:200", "2:50"},
+		},
+		{
+			name:    "unsorted",
+			shards:  []string{concatTestShards[2], concatTestShards[0]},
+			opts:    []ConcatOption{RemoveDuplicates()},
+			wantErr: "1:100 in",
+		},
+		{
+			name:    "samples",
+			shards:  []string{concatTestShards[0], strings.Replace(concatTestShards[2], "S1\tS2", "S2\tS1", 1)},
+			wantErr: "same samples",
+		},
+		{
+			name:    "header",
+			shards:  []string{concatTestShards[0], strings.Replace(concatTestShards[2], "Number=1", "Number=2", 1)},
+			wantErr: "INFO DP is defined with Number=1 and Number=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.vcf.gz")
+			w, err := NewWriter(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Concat(w, concatTestVCFs(t, tt.shards...), tt.opts...)
+			if cerr := w.Close(); cerr != nil {
+				t.Fatal(cerr)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Concat() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Concat() error = %v", err)
+			}
+			v, err := New(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewScanner(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, x := range scanAll(t, s) {
+				got = append(got, fmt.Sprintf("%s:%d", x.Chrom, x.Pos))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Concat() wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
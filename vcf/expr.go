@@ -0,0 +1,855 @@
+package vcf
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expr is a compiled filter expression (see Compile).
+type Expr struct {
+	src    string
+	header Header
+	root   node
+}
+
+// Compile compiles a filter expression, similar to those of `bcftools view
+// -i`, for variants with the header h. For example:
+//
+//	QUAL >= 30 && INFO/DP > 10 && !IsFiltered()
+//	Type() == "SNP" && All(FMT/GQ >= 20)
+//	FILTER == "PASS" && Sample("NA12878", GT == "het")
+//
+// The fields are CHROM, POS, ID, REF, ALT, QUAL, FILTER, INFO/<key>,
+// FMT/<key> (or FORMAT/<key>) and GT; a bare key is an INFO field if the
+// header defines one, otherwise a FORMAT field. An element of a field with
+// several values is selected with an index, for example, INFO/AF[0] or
+// FMT/AD[1]. INFO and FORMAT fields must be defined in the header, and are
+// numbers or strings according to their Type.
+//
+// Values are compared with ==, !=, <, <=, > and >=, and strings are matched
+// against regular expressions with ~ and !~. Strings are quoted with single
+// or double quotes. A field with several values, for example, ALT or an INFO
+// field with Number=A, matches if any of its values does; missing values
+// never match. FILTER == "PASS" is true if no filters failed, FILTER == "a;b"
+// if exactly the filters a and b failed and FILTER ~ "a" if a is one of the
+// filters that failed. GT may be compared with a genotype, for example,
+// GT == "0|1", or with one of "homref", "homvar", "hom", "het", "hetnonref",
+// "called", "partial" and "nocall".
+//
+// A field on its own, for example, INFO/DB, is true if it is present and
+// not missing. Expressions are combined with &&, || and !, and grouped with
+// parentheses. The functions Type(), which returns the name of the Type of
+// the variant, IsSNP(), IsINDEL() and IsFiltered() call the Variant methods
+// of the same name.
+//
+// FORMAT fields and GT refer to every sample, so FMT/DP > 10 is true if any
+// sample has DP greater than 10. Any(expr) and All(expr) are true if expr is
+// true for any or all samples, with the fields in expr referring to each
+// sample in turn, and Sample(name, expr) evaluates expr for a single sample.
+func Compile(expr string, h Header) (*Expr, error) {
+	tokens, err := lexExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile %q: %w", expr, err)
+	}
+	p := &exprParser{tokens: tokens, header: h}
+	root, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile %q: %w", expr, err)
+	}
+	return &Expr{src: expr, header: h, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match returns true if v matches the expression. An error is returned if a
+// field used by the expression can not be parsed.
+func (e *Expr) Match(v Variant) (bool, error) {
+	if v.header == nil {
+		v.header = &e.header
+	}
+	x, err := e.root.eval(&evalContext{v: v, sample: -1})
+	if err != nil {
+		return false, err
+	}
+	return x.truth(), nil
+}
+
+// SetFilter makes the Scanner skip variants that do not match e. A nil e
+// removes the filter.
+func (s *Scanner) SetFilter(e *Expr) {
+	s.filter = e
+}
+
+// matches returns true if v passes the Scanner's filter, if any.
+func (s *Scanner) matches(v Variant) (bool, error) {
+	if s.filter == nil {
+		return true, nil
+	}
+	return s.filter.Match(v)
+}
+
+// exprKind is the type of the values of a node.
+type exprKind int
+
+const (
+	kindBool exprKind = iota
+	kindNumber
+	kindString
+)
+
+func (k exprKind) String() string {
+	switch k {
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	}
+	return "boolean"
+}
+
+// value is the result of evaluating a node. Each element is a bool, float64
+// or string, or nil if it is missing.
+type value []interface{}
+
+// truth returns true if any element is true or, for numbers and strings,
+// present.
+func (x value) truth() bool {
+	for _, e := range x {
+		if b, ok := e.(bool); !ok && e != nil || b {
+			return true
+		}
+	}
+	return false
+}
+
+func boolValue(b bool) value {
+	return value{b}
+}
+
+type evalContext struct {
+	v Variant
+	// sample is the index of the sample FORMAT fields refer to, or -1 for
+	// every sample.
+	sample int
+}
+
+// genotypes returns the genotypes FORMAT fields refer to.
+func (c *evalContext) genotypes() []Genotype {
+	gs := c.v.Genotypes()
+	if c.sample < 0 {
+		return gs
+	}
+	if c.sample < len(gs) {
+		return gs[c.sample : c.sample+1]
+	}
+	return nil
+}
+
+type node interface {
+	kind() exprKind
+	eval(c *evalContext) (value, error)
+}
+
+type literalNode struct {
+	x interface{}
+	k exprKind
+}
+
+func (n literalNode) kind() exprKind { return n.k }
+
+func (n literalNode) eval(c *evalContext) (value, error) {
+	return value{n.x}, nil
+}
+
+// fieldNode is a column or an INFO or FORMAT field, optionally indexed.
+type fieldNode struct {
+	name string
+	// key is the INFO or FORMAT key, or "" for columns.
+	key    string
+	k      exprKind
+	format bool
+	index  int
+}
+
+func (n fieldNode) kind() exprKind { return n.k }
+
+func (n fieldNode) eval(c *evalContext) (value, error) {
+	v := c.v
+	var xs []string
+	switch {
+	case n.format:
+		var ret value
+		for _, g := range c.genotypes() {
+			var ys []string
+			if n.key == "GT" {
+				if gt, ok := g.values["GT"]; ok {
+					ys = []string{gt}
+				}
+			} else if _, ok := g.values[n.key]; ok {
+				var err error
+				if ys, _, err = g.formatValues(n.key); err != nil {
+					return nil, err
+				}
+			}
+			x, err := n.convert(ys)
+			if err != nil {
+				return nil, g.formatError(n.key, err)
+			}
+			ret = append(ret, x...)
+		}
+		return ret, nil
+	case n.key != "":
+		if _, ok := v.Info[n.key]; !ok {
+			return value{}, nil
+		}
+		if n.k == kindBool {
+			return boolValue(true), nil
+		}
+		var err error
+		if xs, _, err = v.infoValues(n.key); err != nil {
+			return nil, err
+		}
+		x, err := n.convert(xs)
+		if err != nil {
+			return nil, v.infoError(n.key, err)
+		}
+		return x, nil
+	}
+	switch n.name {
+	case "CHROM":
+		xs = []string{v.Chrom}
+	case "POS":
+		xs = []string{strconv.Itoa(v.Pos)}
+	case "ID":
+		xs = strings.Split(v.ID, ";")
+	case "REF":
+		xs = []string{v.Ref}
+	case "ALT":
+		xs = v.Alt
+	case "QUAL":
+		xs = []string{v.Qual}
+	case "FILTER":
+		xs = v.Filter
+		if !v.IsFiltered() {
+			xs = []string{"PASS"}
+		}
+	}
+	x, err := n.convert(xs)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%d: %s: %w", v.Chrom, v.Pos, n.name, err)
+	}
+	return x, nil
+}
+
+// convert converts the elements of a field, selecting the indexed element
+// if there is an index.
+func (n fieldNode) convert(xs []string) (value, error) {
+	if n.index >= 0 {
+		if n.index >= len(xs) {
+			return value{nil}, nil
+		}
+		xs = xs[n.index : n.index+1]
+	}
+	ret := make(value, len(xs))
+	for i, x := range xs {
+		if x == "." || x == "" {
+			continue
+		}
+		if n.k != kindNumber {
+			ret[i] = x
+			continue
+		}
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", x)
+		}
+		ret[i] = f
+	}
+	return ret, nil
+}
+
+// compareNode compares the values of two nodes.
+type compareNode struct {
+	op   string
+	l, r node
+	re   *regexp.Regexp
+}
+
+func (n compareNode) kind() exprKind { return kindBool }
+
+func (n compareNode) eval(c *evalContext) (value, error) {
+	l, err := n.l.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	if n.re != nil {
+		for _, x := range l {
+			if s, ok := x.(string); ok && n.re.MatchString(s) == (n.op == "~") {
+				return boolValue(true), nil
+			}
+		}
+		return boolValue(false), nil
+	}
+	r, err := n.r.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, x := range l {
+		for _, y := range r {
+			if x != nil && y != nil && compare(n.op, x, y) {
+				return boolValue(true), nil
+			}
+		}
+	}
+	return boolValue(false), nil
+}
+
+func compare(op string, x, y interface{}) bool {
+	if a, ok := x.(float64); ok {
+		b := y.(float64)
+		switch op {
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		}
+	}
+	if op == "!=" {
+		return x != y
+	}
+	return x == y
+}
+
+// filterNode compares FILTER with a set of filters, or tests if it
+// contains a filter.
+type filterNode struct {
+	op      string
+	filters []string
+}
+
+func (n filterNode) kind() exprKind { return kindBool }
+
+func (n filterNode) eval(c *evalContext) (value, error) {
+	v := c.v
+	var ret bool
+	switch n.op {
+	case "~", "!~":
+		ret = stringSliceContains(v.Filter, n.filters[0]) == (n.op == "~")
+	default:
+		same := v.IsFiltered() == (len(n.filters) > 0)
+		for _, f := range n.filters {
+			same = same && stringSliceContains(v.Filter, f)
+		}
+		for _, f := range v.Filter {
+			same = same && (f == "PASS" || f == "." || stringSliceContains(n.filters, f))
+		}
+		ret = same == (n.op == "==")
+	}
+	return boolValue(ret), nil
+}
+
+// gtClasses are the classes of genotype GT can be compared with.
+var gtClasses = map[string]func(Genotype) bool{
+	"homref":    Genotype.IsHomRef,
+	"homvar":    Genotype.IsHomVar,
+	"hom":       Genotype.IsHom,
+	"het":       Genotype.IsHet,
+	"hetnonref": Genotype.IsHetNonRef,
+	"called":    Genotype.IsCalled,
+	"partial":   Genotype.IsPartiallyCalled,
+	"nocall":    Genotype.IsNoCall,
+}
+
+// gtClassNode tests if the genotypes are, or are not, in a class.
+type gtClassNode struct {
+	op    string
+	class func(Genotype) bool
+}
+
+func (n gtClassNode) kind() exprKind { return kindBool }
+
+func (n gtClassNode) eval(c *evalContext) (value, error) {
+	for _, g := range c.genotypes() {
+		if n.class(g) == (n.op == "==") {
+			return boolValue(true), nil
+		}
+	}
+	return boolValue(false), nil
+}
+
+type logicNode struct {
+	op   string
+	l, r node
+}
+
+func (n logicNode) kind() exprKind { return kindBool }
+
+func (n logicNode) eval(c *evalContext) (value, error) {
+	l, err := n.l.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return boolValue(!l.truth()), nil
+	}
+	// Short circuit.
+	if l.truth() == (n.op == "||") {
+		return boolValue(l.truth()), nil
+	}
+	r, err := n.r.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	return boolValue(r.truth()), nil
+}
+
+// variantFuncs are the functions of the variant that take no arguments.
+var variantFuncs = map[string]func(Variant) interface{}{
+	"Type":       func(v Variant) interface{} { return v.Type().String() },
+	"IsSNP":      func(v Variant) interface{} { return v.IsSNP() },
+	"IsINDEL":    func(v Variant) interface{} { return v.IsINDEL() },
+	"IsFiltered": func(v Variant) interface{} { return v.IsFiltered() },
+}
+
+type funcNode struct {
+	f func(Variant) interface{}
+	k exprKind
+}
+
+func (n funcNode) kind() exprKind { return n.k }
+
+func (n funcNode) eval(c *evalContext) (value, error) {
+	return value{n.f(c.v)}, nil
+}
+
+// sampleNode evaluates an expression for each sample, or a single sample.
+type sampleNode struct {
+	all bool
+	// sample is the index of the sample, or -1 for every sample.
+	sample int
+	x      node
+}
+
+func (n sampleNode) kind() exprKind { return kindBool }
+
+func (n sampleNode) eval(c *evalContext) (value, error) {
+	if n.sample >= 0 {
+		x, err := n.x.eval(&evalContext{v: c.v, sample: n.sample})
+		if err != nil {
+			return nil, err
+		}
+		return boolValue(x.truth()), nil
+	}
+	for i := range c.v.Genotypes() {
+		x, err := n.x.eval(&evalContext{v: c.v, sample: i})
+		if err != nil {
+			return nil, err
+		}
+		if x.truth() != n.all {
+			return boolValue(!n.all), nil
+		}
+	}
+	return boolValue(n.all), nil
+}
+
+type exprToken struct {
+	// kind is one of "ident", "number", "string", "op" and "eof".
+	kind string
+	text string
+	pos  int
+}
+
+var (
+	numberTokenRegexp = regexp.MustCompile(`^([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?`)
+	identTokenRegexp  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./]*`)
+)
+
+// exprOps are the operators, longest first.
+var exprOps = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "=", "!", "~", "(", ")", "[", "]", ",", "-"}
+
+func lexExpr(s string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, exprToken{"string", s[i+1 : i+1+j], i})
+			i += j + 2
+			continue
+		}
+		if m := numberTokenRegexp.FindString(s[i:]); m != "" {
+			tokens = append(tokens, exprToken{"number", m, i})
+			i += len(m)
+			continue
+		}
+		if m := identTokenRegexp.FindString(s[i:]); m != "" {
+			tokens = append(tokens, exprToken{"ident", m, i})
+			i += len(m)
+			continue
+		}
+		found := false
+		for _, op := range exprOps {
+			if strings.HasPrefix(s[i:], op) {
+				tokens = append(tokens, exprToken{"op", op, i})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+		}
+	}
+	return append(tokens, exprToken{"eof", "", len(s)}), nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	header Header
+	// inSample is true inside Any, All and Sample.
+	inSample bool
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[0]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[0]
+	if t.kind != "eof" {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op.
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == "op" && t.text == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected() error {
+	t := p.peek()
+	if t.kind == "eof" {
+		return errors.New("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+func (p *exprParser) parse() (node, error) {
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != "eof" {
+		return nil, p.unexpected()
+	}
+	return n, nil
+}
+
+func (p *exprParser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = logicNode{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = logicNode{op: "&&", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseNot() (node, error) {
+	if p.accept("!") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return logicNode{op: "!", l: x}, nil
+	}
+	return p.parseCompare()
+}
+
+var compareOps = []string{"==", "=", "!=", "<=", ">=", "<", ">", "~", "!~"}
+
+func (p *exprParser) parseCompare() (node, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != "op" || !stringSliceContains(compareOps, t.text) {
+		return l, nil
+	}
+	p.next()
+	op := t.text
+	if op == "=" {
+		op = "=="
+	}
+	r, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return p.compareNode(op, l, r, t.pos)
+}
+
+// compareNode checks the kinds of a comparison and returns its node.
+func (p *exprParser) compareNode(op string, l, r node, pos int) (node, error) {
+	lit, isLiteral := r.(literalNode)
+	if f, ok := l.(fieldNode); ok && isLiteral && lit.k == kindString {
+		switch {
+		case f.name == "FILTER" && f.index < 0:
+			return newFilterNode(op, lit.x.(string))
+		case f.key == "GT" && f.format && gtClasses[lit.x.(string)] != nil:
+			if op != "==" && op != "!=" {
+				return nil, fmt.Errorf("GT can not be compared with %s", op)
+			}
+			return gtClassNode{op: op, class: gtClasses[lit.x.(string)]}, nil
+		}
+	}
+	if op == "~" || op == "!~" {
+		if l.kind() != kindString || !isLiteral || lit.k != kindString {
+			return nil, fmt.Errorf("%s at position %d must match a string with a quoted regular expression", op, pos)
+		}
+		re, err := regexp.Compile(lit.x.(string))
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: op, l: l, re: re}, nil
+	}
+	if l.kind() != r.kind() {
+		return nil, fmt.Errorf("can not compare %s and %s at position %d", l.kind(), r.kind(), pos)
+	}
+	if l.kind() != kindNumber && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("can not compare %ss with %s at position %d", l.kind(), op, pos)
+	}
+	if f, ok := l.(funcNode); ok && f.k == kindString && isLiteral {
+		if !stringSliceContains(typeNames, lit.x.(string)) {
+			return nil, fmt.Errorf("unknown variant type %q", lit.x)
+		}
+	}
+	return compareNode{op: op, l: l, r: r}, nil
+}
+
+func newFilterNode(op, s string) (node, error) {
+	var filters []string
+	for _, f := range strings.Split(s, ";") {
+		if f != "PASS" && f != "." && f != "" {
+			filters = append(filters, f)
+		}
+	}
+	switch op {
+	case "==", "!=":
+	case "~", "!~":
+		if len(filters) != 1 {
+			return nil, fmt.Errorf("FILTER %s must be given a single filter", op)
+		}
+	default:
+		return nil, fmt.Errorf("FILTER can not be compared with %s", op)
+	}
+	return filterNode{op: op, filters: filters}, nil
+}
+
+func (p *exprParser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case "number":
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return literalNode{f, kindNumber}, nil
+	case "string":
+		return literalNode{t.text, kindString}, nil
+	case "ident":
+		if p.accept("(") {
+			return p.parseCall(t)
+		}
+		return p.parseField(t)
+	case "op":
+		switch t.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "-":
+			if n := p.peek(); n.kind == "number" {
+				p.next()
+				f, err := strconv.ParseFloat(n.text, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q at position %d", n.text, n.pos)
+				}
+				return literalNode{-f, kindNumber}, nil
+			}
+		}
+	}
+	p.tokens = append([]exprToken{t}, p.tokens...)
+	return nil, p.unexpected()
+}
+
+// columns are the kinds of the fixed columns.
+var columns = map[string]exprKind{
+	"CHROM":  kindString,
+	"POS":    kindNumber,
+	"ID":     kindString,
+	"REF":    kindString,
+	"ALT":    kindString,
+	"QUAL":   kindNumber,
+	"FILTER": kindString,
+}
+
+func (p *exprParser) parseField(t exprToken) (node, error) {
+	f := fieldNode{name: t.text, index: -1}
+	var key string
+	switch {
+	case strings.HasPrefix(t.text, "INFO/"):
+		key = t.text[len("INFO/"):]
+	case strings.HasPrefix(t.text, "FMT/"):
+		key, f.format = t.text[len("FMT/"):], true
+	case strings.HasPrefix(t.text, "FORMAT/"):
+		key, f.format = t.text[len("FORMAT/"):], true
+	case t.text == "GT":
+		key, f.format = "GT", true
+	default:
+		if k, ok := columns[t.text]; ok {
+			f.k = k
+			break
+		}
+		key = t.text
+		if _, ok := p.header.definition("INFO", key); !ok {
+			if _, ok := p.header.definition("FORMAT", key); !ok {
+				return nil, fmt.Errorf("%s is not a column or a field defined in the header", key)
+			}
+			f.format = true
+		}
+	}
+	if key != "" {
+		f.key = key
+		what := "INFO"
+		if f.format {
+			what = "FORMAT"
+		}
+		def, ok := p.header.definition(what, key)
+		if !ok {
+			return nil, fmt.Errorf("%s %s is not defined in the header", what, key)
+		}
+		switch def.Get("Type") {
+		case "Integer", "Float":
+			f.k = kindNumber
+		case "Flag":
+			if f.format {
+				return nil, fmt.Errorf("FORMAT %s has Type=Flag", key)
+			}
+			f.k = kindBool
+		default:
+			f.k = kindString
+		}
+	}
+	if p.accept("[") {
+		n := p.next()
+		i, err := strconv.Atoi(n.text)
+		if n.kind != "number" || err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid index %q at position %d", n.text, n.pos)
+		}
+		if f.k == kindBool {
+			return nil, fmt.Errorf("%s can not be indexed", t.text)
+		}
+		f.index = i
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *exprParser) parseCall(t exprToken) (node, error) {
+	if f, ok := variantFuncs[t.text]; ok {
+		k := kindBool
+		if t.text == "Type" {
+			k = kindString
+		}
+		return funcNode{f: f, k: k}, p.expect(")")
+	}
+	n := sampleNode{all: t.text == "All", sample: -1}
+	switch t.text {
+	case "Any", "All":
+	case "Sample":
+		name := p.next()
+		if name.kind != "string" {
+			return nil, fmt.Errorf("Sample at position %d must be given a quoted sample name", t.pos)
+		}
+		n.sample = -1
+		for i, s := range p.header.Samples {
+			if s == name.text {
+				n.sample = i
+			}
+		}
+		if n.sample < 0 {
+			return nil, fmt.Errorf("unknown sample %q", name.text)
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown function %s at position %d", t.text, t.pos)
+	}
+	if p.inSample {
+		return nil, fmt.Errorf("%s at position %d is inside Any, All or Sample", t.text, t.pos)
+	}
+	p.inSample = true
+	x, err := p.parseOr()
+	p.inSample = false
+	if err != nil {
+		return nil, err
+	}
+	n.x = x
+	return n, p.expect(")")
+}
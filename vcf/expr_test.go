@@ -0,0 +1,175 @@
+package vcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+const exprTestVCF = `##fileformat=VCFv4.2
+##FILTER=<ID=PASS,Description="All filters passed">
+##FILTER=<ID=q10,Description="Quality below 10">
+##FILTER=<ID=s50,Description="Less than 50% of samples have data">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP membership">
+##INFO=<ID=GENE,Number=1,Type=String,Description="Gene">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Read depth">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##contig=<ID=1,length=249250621>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	rs1	A	C	50	PASS	DP=20;AF=0.5;DB;GENE=BRCA1	GT:DP:AD	0/1:10:5,5	0/0:10:10,0
+1	200	.	AT	A	5	q10	DP=5;AF=0.25;GENE=BRCA2	GT:DP:AD	0/0:3:3,0	0|1:2:1,1
+1	300	rs3	G	T,C	99	q10;s50	DP=40;AF=0.05,0.75	GT:DP:AD	1/2:20:0,10,10	./.:.:.
+1	400	.	CA	GT	.	.	.	GT:DP:AD	1/1:30:0,30	0/.:5:5,0
+`
+
+func TestCompile(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.vcf", exprTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants := scanAll(t, s)
+	tests := []struct {
+		expr string
+		want []int
+	}{
+		{"QUAL >= 30", []int{100, 300}},
+		{"QUAL < 30", []int{200}},
+		{"INFO/DP > 10 && INFO/DP <= 40", []int{100, 300}},
+		{"DP > 10", []int{100, 300}},
+		{"INFO/DP == 5 || POS = 400", []int{200, 400}},
+		{"INFO/AF > 0.7", []int{300}},
+		{"INFO/AF[0] > 0.7", nil},
+		{"INFO/AF[1] > 0.7", []int{300}},
+		{"INFO/DB", []int{100}},
+		{"!INFO/DB", []int{200, 300, 400}},
+		{"INFO/GENE ~ '^BRCA'", []int{100, 200}},
+		{"INFO/GENE !~ '1$'", []int{200}},
+		{`INFO/GENE == "BRCA2"`, []int{200}},
+		{"ID == 'rs3'", []int{300}},
+		{"ID", []int{100, 300}},
+		{"CHROM == '1' && REF == 'AT'", []int{200}},
+		{"ALT == 'C'", []int{100, 300}},
+		{"FILTER == 'PASS'", []int{100, 400}},
+		{"FILTER == '.'", []int{100, 400}},
+		{"FILTER != 'PASS'", []int{200, 300}},
+		{"FILTER == 'q10'", []int{200}},
+		{"FILTER == 's50;q10'", []int{300}},
+		{"FILTER ~ 'q10'", []int{200, 300}},
+		{"FILTER !~ 's50'", []int{100, 200, 400}},
+		{"Type() == 'SNP'", []int{100, 300}},
+		{"Type() == 'MIXED' || Type() == 'MNP'", []int{400}},
+		{"IsSNP()", []int{100, 300}},
+		{"IsINDEL()", []int{200}},
+		{"IsFiltered()", []int{200, 300}},
+		{"!IsFiltered() && (QUAL > 10 || INFO/DP < 10)", []int{100}},
+		{"FMT/DP > 20", []int{400}},
+		{"FORMAT/DP >= 20", []int{300, 400}},
+		{"FMT/AD[1] == 10", []int{300}},
+		{"All(FMT/DP >= 10)", []int{100}},
+		{"Any(FMT/DP < 5)", []int{200}},
+		{"Any(FMT/DP >= 10 && GT == 'het')", []int{100, 300}},
+		{"Any(FMT/DP < 10 && GT == 'homvar')", nil},
+		{"FMT/DP < 10 && GT == 'homvar'", []int{400}},
+		{"All(FMT/DP)", []int{100, 200, 400}},
+		{`Sample("S2", FMT/DP == 10)`, []int{100}},
+		{"GT == 'het'", []int{100, 200, 300}},
+		{"GT == 'hetnonref'", []int{300}},
+		{"GT == 'homvar'", []int{400}},
+		{"GT == 'nocall'", []int{300}},
+		{"GT == 'partial'", []int{400}},
+		{"GT != 'called'", []int{300, 400}},
+		{"GT == '0|1'", []int{200}},
+		{"Sample('S1', GT == 'homref')", []int{200}},
+		{"QUAL > -1", []int{100, 200, 300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Compile(tt.expr, v.Header)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			var got []int
+			for _, x := range variants {
+				ok, err := e.Match(x)
+				if err != nil {
+					t.Fatalf("Expr.Match() error = %v", err)
+				}
+				if ok {
+					got = append(got, x.Pos)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expr.Match() matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_errors(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.vcf", exprTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []string{
+		"",
+		"QUAL >",
+		"QUAL > 'high'",
+		"INFO/GENE > 1",
+		"INFO/XX > 1",
+		"FMT/XX > 1",
+		"XX",
+		"INFO/DB[0]",
+		"INFO/DP ~ '1'",
+		"INFO/GENE ~ '('",
+		"CHROM < 'chr2'",
+		"FILTER < 'q10'",
+		"FILTER ~ 'q10;s50'",
+		"GT > 'het'",
+		"Type() == 'snp'",
+		"Unknown()",
+		"Sample('S3', GT == 'het')",
+		"Sample(GT == 'het')",
+		"Any(All(FMT/DP > 1))",
+		"(QUAL > 1",
+		"QUAL > 1)",
+		"QUAL > 1 & DP > 1",
+		"INFO/GENE == 'BRCA1",
+		"INFO/AF[x] > 1",
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Compile(expr, v.Header); err == nil {
+				t.Errorf("Compile(%q) did not return an error", expr)
+			}
+		})
+	}
+}
+
+func TestScanner_SetFilter(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.vcf", exprTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Compile("QUAL >= 30 || GT == 'partial'", v.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetFilter(e)
+	var got []int
+	for _, x := range scanAll(t, s) {
+		got = append(got, x.Pos)
+	}
+	if want := []int{100, 300, 400}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Scanner variants = %v, want %v", got, want)
+	}
+}
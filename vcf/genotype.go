@@ -7,13 +7,19 @@ import (
 	"strings"
 )
 
+// MissingAllele is the index of a missing (".") allele in a genotype.
+const MissingAllele = -1
+
 // Genotype represents an individual genotyped sample in a VCF file.
 type Genotype struct {
 	Name          string
 	values        map[string]string
 	alleleIndexes []int
-	phased        bool
-	v             *Variant
+	// phased is true if every allele is phased.
+	phased bool
+	// phasing[i] is true if allele i is phased with the previous alleles.
+	phasing []bool
+	v       *Variant
 }
 
 func NewGenotype(name string, attributes map[string]string) (Genotype, error) {
@@ -28,40 +34,68 @@ func NewGenotype(name string, attributes map[string]string) (Genotype, error) {
 	// that it must be the first field if present.
 	gt, ok := attributes["GT"]
 	if ok {
-		// The TSO500 Local App puts these non-standard genotypes in its
-		// output. There appear when all reads in the AD count support
-		// the alt allele but the DP is higher. Presumably some reads
-		// are filtered.
-		if gt == "1/." {
-			gt = "1/1"
-		}
-		// Only attempt to convert the indexes if they are not no-calls. What about non-diplody organisms.
-		if gt != "./." && gt != ".|." && gt != "." {
-			sep := "/"
-			if strings.Contains(gt, "|") {
-				sep = "|"
-				g.phased = true
-			}
-			for _, istr := range strings.Split(gt, sep) {
-				// TSO500 is outputting strange calls, for example, 1/.
-				if istr == "." {
-					continue
-				}
-				i, err := strconv.Atoi(istr)
-				if err != nil {
-					return Genotype{}, fmt.Errorf("unable to convert %s to int: %w", istr, err)
-				}
-				g.alleleIndexes = append(g.alleleIndexes, i)
-			}
+		var err error
+		g.alleleIndexes, g.phasing, err = parseGT(gt)
+		if err != nil {
+			return Genotype{}, err
+		}
+		g.phased = true
+		for _, p := range g.phasing {
+			g.phased = g.phased && p
 		}
 	}
 	return g, nil
 }
 
-// Allele(i int) what should this return?
+// parseGT parses a GT value of any ploidy, for example, "0/1", "1", ".|1"
+// or "0/1|2", returning the index of each allele (MissingAllele if it is
+// missing) and whether each allele is phased. The phasing of the first
+// allele may be given by a "/" or "|" prefix, as in VCF 4.4, otherwise it
+// is phased if all the other alleles are.
+func parseGT(gt string) ([]int, []bool, error) {
+	if gt == "" {
+		return nil, nil, errors.New("empty GT")
+	}
+	s := gt
+	first := byte(0)
+	if s[0] == '/' || s[0] == '|' {
+		first, s = s[0], s[1:]
+	}
+	var xs []int
+	phasing := []bool{first == '|'}
+	for {
+		i := strings.IndexAny(s, "/|")
+		allele := s
+		if i >= 0 {
+			allele = s[:i]
+		}
+		x := MissingAllele
+		if allele != "." {
+			a, err := strconv.Atoi(allele)
+			if err != nil || a < 0 {
+				return nil, nil, fmt.Errorf("invalid genotype %q", gt)
+			}
+			x = a
+		}
+		xs = append(xs, x)
+		if i < 0 {
+			break
+		}
+		phasing = append(phasing, s[i] == '|')
+		s = s[i+1:]
+	}
+	if first == 0 && len(xs) > 1 {
+		phasing[0] = true
+		for _, p := range phasing[1:] {
+			phasing[0] = phasing[0] && p
+		}
+	}
+	return xs, phasing, nil
+}
 
-// Alleles returns a slice containing the alleles in this sample. May be an
-// empty slice if the sample was not called.
+// Alleles returns the alleles in this sample, one for each allele of the
+// GT field, with "." for missing alleles. It returns an error if there is
+// no GT field.
 func (g Genotype) Alleles() ([]string, error) {
 	xs := []string{}
 	alleles := g.v.Alleles()
@@ -69,45 +103,75 @@ func (g Genotype) Alleles() ([]string, error) {
 		return []string{}, errors.New("genotype has no alleles")
 	}
 	for _, i := range g.alleleIndexes {
+		if i == MissingAllele {
+			xs = append(xs, ".")
+			continue
+		}
 		if len(alleles) < i+1 {
 			return []string{}, fmt.Errorf("GT has index %d, but the variant only has %d alleles", i, len(alleles))
 		}
 		xs = append(xs, alleles[i])
-
 	}
 	return xs, nil
 }
 
-// IsPhased returns true if the alleles are phased.
+// AlleleIndexes returns the index of each allele of the GT field, with
+// MissingAllele for missing alleles, or nil if there is no GT field.
+func (g Genotype) AlleleIndexes() []int {
+	if g.alleleIndexes == nil {
+		return nil
+	}
+	return append([]int{}, g.alleleIndexes...)
+}
+
+// IsPhased returns true if every allele is phased.
 func (g Genotype) IsPhased() bool {
 	return g.phased
 }
 
-// Ploidy returns the ploidy of this genotype; 0 if the site is no-called.
+// IsAllelePhased returns true if allele i is phased with the previous
+// alleles, for example, allele 2 of "0/1|2". The first allele is phased if
+// it has a "|" prefix or all the other alleles are phased.
+func (g Genotype) IsAllelePhased(i int) bool {
+	return i >= 0 && i < len(g.phasing) && g.phasing[i]
+}
+
+// Ploidy returns the number of alleles in the GT field, including missing
+// alleles; 0 if there is no GT field.
 func (g Genotype) Ploidy() int {
 	return len(g.alleleIndexes)
 }
 
-// GenotypeString() string
-
-// IsCalled returns true if this genotype is comprised of alleles that are all
-// called.
-func (g Genotype) IsCalled() bool {
-	sep := "/"
-	if g.IsPhased() {
-		sep = "|"
-	}
-	gt, err := g.Attribute("GT")
-	if err != nil {
-		return false
-	}
-	for _, i := range strings.Split(gt, sep) {
-		if i == "." {
-			return false
+// missingAlleles returns the number of missing alleles.
+func (g Genotype) missingAlleles() int {
+	n := 0
+	for _, i := range g.alleleIndexes {
+		if i == MissingAllele {
+			n++
 		}
 	}
-	return true
+	return n
+}
+
+// IsCalled returns true if this genotype has alleles and they are all
+// called. Exactly one of IsCalled, IsPartiallyCalled and IsNoCall is true.
+func (g Genotype) IsCalled() bool {
+	return g.Ploidy() > 0 && g.missingAlleles() == 0
 }
+
+// IsPartiallyCalled returns true if some, but not all, of the alleles are
+// missing, for example, "0/.".
+func (g Genotype) IsPartiallyCalled() bool {
+	n := g.missingAlleles()
+	return n > 0 && n < g.Ploidy()
+}
+
+// IsNoCall returns true if every allele is missing, or there is no GT
+// field.
+func (g Genotype) IsNoCall() bool {
+	return g.missingAlleles() == g.Ploidy()
+}
+
 func intSliceAllEqual(xs []int) bool {
 	for i := 1; i < len(xs); i++ {
 		if xs[i] != xs[0] {
@@ -117,24 +181,26 @@ func intSliceAllEqual(xs []int) bool {
 	return true
 }
 
+// IsHom returns true if the genotype is called and every allele is the same.
+// The zygosity methods are false for genotypes that are not fully called.
 func (g Genotype) IsHom() bool {
-	return intSliceAllEqual(g.alleleIndexes)
+	return g.IsCalled() && intSliceAllEqual(g.alleleIndexes)
 }
 
 func (g Genotype) IsHomRef() bool {
-	return g.alleleIndexes[0] == 0 && intSliceAllEqual(g.alleleIndexes)
+	return g.IsHom() && g.alleleIndexes[0] == 0
 }
 
 func (g Genotype) IsHomVar() bool {
-	return g.alleleIndexes[0] != 0 && intSliceAllEqual(g.alleleIndexes)
+	return g.IsHom() && g.alleleIndexes[0] != 0
 }
 
 func (g Genotype) IsHet() bool {
-	return !g.IsHom()
+	return g.IsCalled() && !intSliceAllEqual(g.alleleIndexes)
 }
 
 func (g Genotype) IsHetNonRef() bool {
-	if g.IsHom() {
+	if !g.IsHet() {
 		return false
 	}
 	for _, i := range g.alleleIndexes {
@@ -145,12 +211,6 @@ func (g Genotype) IsHetNonRef() bool {
 	return true
 }
 
-// IsHet(), IsHetNonRef(), IsHom(), IsHomRef(), IsHomVar(),
-
-func (g Genotype) IsNoCall() bool {
-	return !g.IsCalled()
-}
-
 // Attribute returns any genotype attribute as a string. It returns a non-nil
 // error if key is not an attribute.
 func (g Genotype) Attribute(key string) (string, error) {
@@ -190,11 +250,147 @@ func (g Genotype) AttributeAsFloat64(key string) (float64, error) {
 	return f, nil
 }
 
+// AttributeAsStringSlice returns the comma separated elements of a genotype
+// attribute. If the header defines the attribute, the number of elements
+// must match its Number. Missing elements are returned as ".". Elements are
+// percent-decoded for VCF 4.3 and later (see PercentDecode).
+func (g Genotype) AttributeAsStringSlice(key string) ([]string, error) {
+	xs, _, err := g.formatValues(key)
+	if err != nil || g.v == nil {
+		return xs, err
+	}
+	ys, err := decodeValues(g.v.header, xs)
+	if err != nil {
+		return nil, g.formatError(key, err)
+	}
+	return ys, nil
+}
+
+// LocalAlleles returns the indexes in the variant of the local alleles of
+// the genotype, starting with the reference allele, as given by the LAA
+// field. Fields with Number=LA, LR or LG, such as LAD and LPL, have values
+// for the local alleles only (see GlobalValues). If there is no LAA field
+// every allele of the variant is local.
+func (g Genotype) LocalAlleles() ([]int, error) {
+	laa, ok := g.values["LAA"]
+	if !ok {
+		if g.v == nil {
+			return nil, errors.New("genotype has no variant")
+		}
+		xs := make([]int, g.v.nAlt()+1)
+		for i := range xs {
+			xs[i] = i
+		}
+		return xs, nil
+	}
+	xs := []int{0}
+	if laa == "." {
+		return xs, nil
+	}
+	for _, x := range strings.Split(laa, ",") {
+		i, err := strconv.Atoi(x)
+		if err != nil || i < 1 || (g.v != nil && i > g.v.nAlt()) {
+			return nil, g.formatError("LAA", fmt.Errorf("invalid allele index %q", x))
+		}
+		xs = append(xs, i)
+	}
+	return xs, nil
+}
+
+// GlobalValues returns the values of a local allele field, with
+// Number=LA, LR or LG, for the alleles of the variant, for example, the AD
+// values from LAD. Values for alleles that are not local are ".".
+func (g Genotype) GlobalValues(key string) ([]string, error) {
+	xs, def, err := g.formatValues(key)
+	if err != nil {
+		return nil, err
+	}
+	number := ""
+	if def != nil {
+		number = def.Get("Number")
+	}
+	if !isLocalNumber(number) {
+		return nil, g.formatError(key, fmt.Errorf("not a local allele field: declared as Number=%s", number))
+	}
+	local, err := g.LocalAlleles()
+	if err != nil {
+		return nil, err
+	}
+	nAlt := g.v.nAlt()
+	ploidy := g.gtPloidy()
+	n := expectedValues(strings.TrimPrefix(number, "L"), nAlt, ploidy)
+	ys := make([]string, n)
+	for i := range ys {
+		ys[i] = "."
+	}
+	if len(xs) == 1 && xs[0] == "." {
+		return ys, nil
+	}
+	switch number {
+	case "LA":
+		for i, x := range xs {
+			ys[local[i+1]-1] = x
+		}
+	case "LR":
+		for i, x := range xs {
+			ys[local[i]] = x
+		}
+	case "LG":
+		for i, gt := range genotypeOrder(len(local), ploidy) {
+			global := make([]int, len(gt))
+			for j, a := range gt {
+				global[j] = local[a]
+			}
+			ys[genotypeIndex(global)] = xs[i]
+		}
+	}
+	return ys, nil
+}
+
+// AttributeAsIntSlice returns the elements of an Integer genotype attribute,
+// for example, AD. If the header defines the attribute, the number of
+// elements must match its Number and its Type must be Integer. Missing
+// elements are returned as MissingInt.
+func (g Genotype) AttributeAsIntSlice(key string) ([]int, error) {
+	xs, def, err := g.formatValues(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkType(def, "Integer"); err != nil {
+		return nil, g.formatError(key, err)
+	}
+	ys, err := parseInts(xs)
+	if err != nil {
+		return nil, g.formatError(key, err)
+	}
+	return ys, nil
+}
+
+// AttributeAsFloat64Slice returns the elements of a Float or Integer
+// genotype attribute. If the header defines the attribute, the number of
+// elements must match its Number. Missing elements are returned as NaN (see
+// IsMissingFloat).
+func (g Genotype) AttributeAsFloat64Slice(key string) ([]float64, error) {
+	xs, def, err := g.formatValues(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkType(def, "Float", "Integer"); err != nil {
+		return nil, g.formatError(key, err)
+	}
+	ys, err := parseFloats(xs)
+	if err != nil {
+		return nil, g.formatError(key, err)
+	}
+	return ys, nil
+}
+
+// AsVCFString returns the values of g as a VCF sample column,
+// percent-encoding any ":" in them.
 func (g Genotype) AsVCFString() string {
 	xs := []string{}
 	for _, format := range g.v.Format {
-		// v, ok := g.values[format]
-		xs = append(xs, g.values[format])
+		xs = append(xs, formatEscaper.Replace(g.values[format]))
 	}
 	return strings.Join(xs, ":")
 }
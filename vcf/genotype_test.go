@@ -2,6 +2,7 @@ package vcf
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -27,6 +28,8 @@ func TestGenotype_Alleles(t *testing.T) {
 		{"t6", fields{"", map[string]string{"GT": "1|1"}, []int{1, 1}, true, &Variant{Ref: "A", Alt: []string{"C"}}}, []string{"C", "C"}, false},
 		{"t7", fields{"", map[string]string{}, []int{}, false, &Variant{Ref: "A", Alt: []string{"C"}}}, []string{}, true},
 		{"t8", fields{"", map[string]string{"GT": "0/2"}, []int{0, 2}, false, &Variant{Ref: "A", Alt: []string{"C"}}}, []string{}, true},
+		{"t9", fields{"", map[string]string{"GT": "0/."}, []int{0, MissingAllele}, false, &Variant{Ref: "A", Alt: []string{"C"}}}, []string{"A", "."}, false},
+		{"t10", fields{"", map[string]string{"GT": "1"}, []int{1}, false, &Variant{Ref: "A", Alt: []string{"C"}}}, []string{"C"}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -48,3 +51,221 @@ func TestGenotype_Alleles(t *testing.T) {
 		})
 	}
 }
+
+func TestGenotype_AttributeAsIntSlice(t *testing.T) {
+	vs := typedTestVariants(t)
+	sample := func(v Variant, name string) Genotype {
+		g, err := v.Sample(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	tests := []struct {
+		name    string
+		g       Genotype
+		key     string
+		want    []int
+		wantErr bool
+	}{
+		{"Number=R", sample(vs[0], "S1"), "AD", []int{10, 10, 0}, false},
+		{"Number=G diploid", sample(vs[0], "S1"), "PL", []int{0, 10, 100, 20, 200, 300}, false},
+		{"Number=G haploid", sample(vs[0], "S2"), "PL", []int{0, 1, 2}, false},
+		{"missing element", sample(vs[0], "S2"), "AD", []int{5, MissingInt, 1}, false},
+		{"missing", sample(vs[0], "S3"), "AD", []int{MissingInt}, false},
+		{"Float", sample(vs[0], "S1"), "VAF", nil, true},
+		{"wrong number R", sample(vs[1], "S2"), "AD", nil, true},
+		{"wrong number G", sample(vs[1], "S1"), "PL", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.g.AttributeAsIntSlice(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Genotype.AttributeAsIntSlice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Genotype.AttributeAsIntSlice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenotype_AttributeAsFloat64Slice(t *testing.T) {
+	vs := typedTestVariants(t)
+	g, err := vs[1].Sample("S3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.AttributeAsFloat64Slice("VAF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Genotype.AttributeAsFloat64Slice() = %v, want %v", got, want)
+	}
+	g, err = vs[1].Sample("S2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.AttributeAsFloat64Slice("VAF")
+	if err == nil || !strings.Contains(err.Error(), "1:200: FORMAT VAF of S2") {
+		t.Errorf("Genotype.AttributeAsFloat64Slice() error = %v, want error naming the field, sample and position", err)
+	}
+}
+
+func TestNewGenotype(t *testing.T) {
+	tests := []struct {
+		gt        string
+		indexes   []int
+		phasing   []bool
+		ploidy    int
+		state     string
+		zygosity  string
+		isPhased  bool
+		wantError bool
+	}{
+		{"0/1", []int{0, 1}, []bool{false, false}, 2, "called", "het", false, false},
+		{"0|1", []int{0, 1}, []bool{true, true}, 2, "called", "het", true, false},
+		{"1/1", []int{1, 1}, []bool{false, false}, 2, "called", "homvar", false, false},
+		{"1/2", []int{1, 2}, []bool{false, false}, 2, "called", "hetnonref", false, false},
+		{"0", []int{0}, []bool{false}, 1, "called", "homref", false, false},
+		{"|1", []int{1}, []bool{true}, 1, "called", "homvar", true, false},
+		{"/0|1", []int{0, 1}, []bool{false, true}, 2, "called", "het", false, false},
+		{"0/0/1", []int{0, 0, 1}, []bool{false, false, false}, 3, "called", "het", false, false},
+		{"0/1|2", []int{0, 1, 2}, []bool{false, false, true}, 3, "called", "het", false, false},
+		{"0|0|0|0", []int{0, 0, 0, 0}, []bool{true, true, true, true}, 4, "called", "homref", true, false},
+		{"0/.", []int{0, MissingAllele}, []bool{false, false}, 2, "partial", "", false, false},
+		{"1/.", []int{1, MissingAllele}, []bool{false, false}, 2, "partial", "", false, false},
+		{".|1", []int{MissingAllele, 1}, []bool{true, true}, 2, "partial", "", true, false},
+		{"./.", []int{MissingAllele, MissingAllele}, []bool{false, false}, 2, "nocall", "", false, false},
+		{".", []int{MissingAllele}, []bool{false}, 1, "nocall", "", false, false},
+		{"", nil, nil, 0, "", "", false, true},
+		{"0/x", nil, nil, 0, "", "", false, true},
+		{"0/-1", nil, nil, 0, "", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.gt, func(t *testing.T) {
+			g, err := NewGenotype("S1", map[string]string{"GT": tt.gt})
+			if (err != nil) != tt.wantError {
+				t.Fatalf("NewGenotype() error = %v, wantErr %v", err, tt.wantError)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(g.AlleleIndexes(), tt.indexes) {
+				t.Errorf("Genotype.AlleleIndexes() = %v, want %v", g.AlleleIndexes(), tt.indexes)
+			}
+			phasing := []bool{}
+			for i := range tt.indexes {
+				phasing = append(phasing, g.IsAllelePhased(i))
+			}
+			if !reflect.DeepEqual(phasing, tt.phasing) {
+				t.Errorf("Genotype.IsAllelePhased() = %v, want %v", phasing, tt.phasing)
+			}
+			if g.Ploidy() != tt.ploidy {
+				t.Errorf("Genotype.Ploidy() = %d, want %d", g.Ploidy(), tt.ploidy)
+			}
+			if g.IsPhased() != tt.isPhased {
+				t.Errorf("Genotype.IsPhased() = %v, want %v", g.IsPhased(), tt.isPhased)
+			}
+			states := map[string]bool{
+				"called":  g.IsCalled(),
+				"partial": g.IsPartiallyCalled(),
+				"nocall":  g.IsNoCall(),
+			}
+			for k, got := range states {
+				if got != (k == tt.state) {
+					t.Errorf("state %s = %v, want state %s", k, got, tt.state)
+				}
+			}
+			zygosity := map[string]bool{
+				"homref":    g.IsHomRef(),
+				"homvar":    g.IsHomVar(),
+				"het":       g.IsHet() && !g.IsHetNonRef(),
+				"hetnonref": g.IsHetNonRef(),
+			}
+			for k, got := range zygosity {
+				if got != (k == tt.zygosity) {
+					t.Errorf("zygosity %s = %v, want %s", k, got, tt.zygosity)
+				}
+			}
+			if g.IsHom() != (tt.zygosity == "homref" || tt.zygosity == "homvar") {
+				t.Errorf("Genotype.IsHom() = %v, want zygosity %s", g.IsHom(), tt.zygosity)
+			}
+		})
+	}
+}
+
+func TestGenotype_noGT(t *testing.T) {
+	g, err := NewGenotype("S1", map[string]string{"DP": "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Ploidy() != 0 || g.IsCalled() || g.IsPartiallyCalled() || !g.IsNoCall() {
+		t.Errorf("Genotype without GT: Ploidy() = %d, IsCalled() = %v, IsPartiallyCalled() = %v, IsNoCall() = %v", g.Ploidy(), g.IsCalled(), g.IsPartiallyCalled(), g.IsNoCall())
+	}
+	if g.IsHom() || g.IsHomRef() || g.IsHomVar() || g.IsHet() || g.IsHetNonRef() {
+		t.Error("Genotype without GT has a zygosity")
+	}
+}
+
+const missingGTTestVCF = `##fileformat=VCFv4.3
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled genotype likelihoods">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	.	A	C,G	.	PASS	.	GT:PL	.:0,1,2,3,4,5	.:0,1,2,3
+1	200	.	A	C,G	.	PASS	.	GT:PL	1:0,1,2	.:.
+X	300	.	A	C	.	PASS	.	GT	.	./.
+`
+
+func TestGenotype_missingGTPloidy(t *testing.T) {
+	s, err := NewReader(strings.NewReader(missingGTTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := scanAll(t, s)
+	sample := func(v Variant, name string) Genotype {
+		g, err := v.Sample(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	ploidies := []struct {
+		name string
+		g    Genotype
+		want int
+	}{
+		{"no other GT", sample(vs[0], "S1"), 2},
+		{"haploid sample", sample(vs[1], "S2"), 1},
+		{"diploid sample", sample(vs[2], "S1"), 2},
+	}
+	for _, tt := range ploidies {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.g.gtPloidy(); got != tt.want {
+				t.Errorf("Genotype.gtPloidy() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	values := []struct {
+		name    string
+		g       Genotype
+		want    []int
+		wantErr bool
+	}{
+		{"Number=G diploid", sample(vs[0], "S1"), []int{0, 1, 2, 3, 4, 5}, false},
+		{"Number=G no ploidy", sample(vs[0], "S2"), nil, true},
+		{"missing", sample(vs[1], "S2"), []int{MissingInt}, false},
+	}
+	for _, tt := range values {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.g.AttributeAsIntSlice("PL")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Genotype.AttributeAsIntSlice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Genotype.AttributeAsIntSlice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package vcf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// nonRefAlleles are the ALT alleles gVCFs use for any unobserved allele:
+// <NON_REF> (GATK) and <*> (bcftools and the VCF specification).
+var nonRefAlleles = []string{"<NON_REF>", "<*>"}
+
+// IsRefBlock returns true if v is a gVCF reference block, a record whose
+// only ALT allele is <NON_REF> or <*>. The genotypes and FORMAT values of a
+// block apply to every position from Pos to END.
+func (v Variant) IsRefBlock() bool {
+	return len(v.Alt) == 1 && stringSliceContains(nonRefAlleles, v.Alt[0])
+}
+
+// QueryGVCF returns the records of the gVCF v that cover chrom:pos, for
+// example, the reference block giving the genotype and depth of each
+// sample at pos, or the variant records at pos. It uses an index if v has
+// one (see NewScanner).
+func QueryGVCF(v VCF, chrom string, pos int) ([]Variant, error) {
+	s, err := NewScanner(v, fmt.Sprintf("%s:%d", chrom, pos))
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	var xs []Variant
+	for s.Scan() {
+		xs = append(xs, s.Variant())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return xs, nil
+}
+
+// refBlock returns a copy of v, which must be a reference block, covering
+// start to end.
+func (v Variant) refBlock(start, end int) Variant {
+	x := v
+	x.Pos = start
+	x.Info = make(map[string]string, len(v.Info))
+	for k, value := range v.Info {
+		x.Info[k] = value
+	}
+	delete(x.Info, "END")
+	if end > start {
+		x.Info["END"] = strconv.Itoa(end)
+	}
+	return x
+}
+
+// BlockExpander expands gVCF reference blocks into a record for each
+// position, without END. Other records are returned unchanged.
+type BlockExpander struct {
+	s       VariantScanner
+	ref     Reference
+	block   Variant
+	pos     int
+	blockOK bool
+	token   Variant
+	err     error
+}
+
+// NewBlockExpander creates a BlockExpander that reads variants from s. The
+// REF allele of each position after the first of a block is read from ref,
+// or is N if ref is nil.
+func NewBlockExpander(s VariantScanner, ref Reference) *BlockExpander {
+	return &BlockExpander{s: s, ref: ref}
+}
+
+// Scan advances to the next record, which is then available from the
+// Variant method. It returns false when there are no more records or an
+// error occurs.
+func (e *BlockExpander) Scan() bool {
+	if e.err != nil {
+		return false
+	}
+	if !e.blockOK {
+		if !e.s.Scan() {
+			return false
+		}
+		v := e.s.Variant()
+		if !v.IsRefBlock() {
+			e.token = v
+			return true
+		}
+		e.block, e.pos, e.blockOK = v, v.Pos, true
+	}
+	x := e.block.refBlock(e.pos, e.pos)
+	if e.pos > e.block.Pos {
+		x.Ref = "N"
+		if e.ref != nil {
+			base, err := e.ref.Query(x.Chrom, e.pos-1, e.pos)
+			if err != nil {
+				e.err = fmt.Errorf("unable to read the reference at %s:%d: %w", x.Chrom, e.pos, err)
+				return false
+			}
+			x.Ref = base
+		}
+	} else {
+		x.Ref = x.Ref[:1]
+	}
+	e.pos++
+	if e.pos > e.block.End() {
+		e.blockOK = false
+	}
+	e.token = x
+	return true
+}
+
+// Variant returns the most recent record read by Scan.
+func (e *BlockExpander) Variant() Variant {
+	return e.token
+}
+
+// Err returns the first error encountered by the BlockExpander or its
+// VariantScanner.
+func (e *BlockExpander) Err() error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.s.Err()
+}
+
+// BlockBander merges adjacent gVCF reference blocks whose samples have the
+// same GT and GQ in the same band, in the same way as the GQ bands of GATK
+// HaplotypeCaller. The merged block has the minimum GQ, DP and MIN_DP of the
+// blocks; other fields are those of the first block. Other records are
+// returned unchanged.
+type BlockBander struct {
+	s       VariantScanner
+	bands   []int
+	pending *Variant
+	queue   []Variant
+	token   Variant
+	err     error
+}
+
+// NewBlockBander creates a BlockBander that reads variants from s. The GQ
+// bands start at each of bands, for example, bands of 0, 20 and 60 give the
+// bands [0, 20), [20, 60) and [60, ∞).
+func NewBlockBander(s VariantScanner, bands ...int) *BlockBander {
+	xs := append([]int{}, bands...)
+	sort.Ints(xs)
+	return &BlockBander{s: s, bands: xs}
+}
+
+// Scan advances to the next record, which is then available from the
+// Variant method. It returns false when there are no more records or an
+// error occurs.
+func (b *BlockBander) Scan() bool {
+	if b.err != nil {
+		return false
+	}
+	if len(b.queue) > 0 {
+		b.token, b.queue = b.queue[0], b.queue[1:]
+		return true
+	}
+	for b.s.Scan() {
+		v := b.s.Variant()
+		if b.pending == nil {
+			if !v.IsRefBlock() {
+				b.token = v
+				return true
+			}
+			b.pending = &v
+			continue
+		}
+		ok, err := b.mergeable(*b.pending, v)
+		if err != nil {
+			b.err = err
+			return false
+		}
+		if ok {
+			if err := b.merge(v); err != nil {
+				b.err = err
+				return false
+			}
+			continue
+		}
+		b.token = *b.pending
+		b.pending = nil
+		if v.IsRefBlock() {
+			b.pending = &v
+		} else {
+			b.queue = append(b.queue, v)
+		}
+		return true
+	}
+	if b.pending != nil {
+		b.token = *b.pending
+		b.pending = nil
+		return true
+	}
+	return false
+}
+
+// band returns the band of the GQ of g, or -1 if it has no GQ.
+func (b *BlockBander) band(g Genotype) (int, error) {
+	if x, ok := g.values["GQ"]; !ok || x == "." {
+		return -1, nil
+	}
+	gq, err := g.AttributeAsInt("GQ")
+	if err != nil {
+		return 0, g.formatError("GQ", err)
+	}
+	return sort.SearchInts(b.bands, gq+1), nil
+}
+
+// mergeable returns true if the reference block v follows the block a and
+// can be merged with it.
+func (b *BlockBander) mergeable(a, v Variant) (bool, error) {
+	if !v.IsRefBlock() || v.Chrom != a.Chrom || v.Pos != a.End()+1 || v.Alt[0] != a.Alt[0] {
+		return false, nil
+	}
+	ga, gv := a.Genotypes(), v.Genotypes()
+	if len(ga) != len(gv) {
+		return false, nil
+	}
+	for i := range ga {
+		if ga[i].Name != gv[i].Name || ga[i].values["GT"] != gv[i].values["GT"] {
+			return false, nil
+		}
+		x, err := b.band(ga[i])
+		if err != nil {
+			return false, err
+		}
+		y, err := b.band(gv[i])
+		if err != nil {
+			return false, err
+		}
+		if x != y {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// merge merges the reference block v into the pending block.
+func (b *BlockBander) merge(v Variant) error {
+	p := *b.pending
+	x := p.refBlock(p.Pos, v.End())
+	x.genotypes = nil
+	gv := v.Genotypes()
+	for i, g := range p.Genotypes() {
+		values := make(map[string]string, len(g.values))
+		for k, value := range g.values {
+			values[k] = value
+		}
+		for _, k := range []string{"GQ", "DP", "MIN_DP"} {
+			m, err := minValue(g, gv[i], k)
+			if err != nil {
+				return err
+			}
+			if m != "" {
+				values[k] = m
+			}
+		}
+		ng, err := NewGenotype(g.Name, values)
+		if err != nil {
+			return g.formatError("GT", err)
+		}
+		if err := x.AddGenotype(ng); err != nil {
+			return err
+		}
+	}
+	b.pending = &x
+	return nil
+}
+
+// minValue returns the minimum of the integer FORMAT field k of a and b,
+// ignoring missing values, or "" if a does not have k.
+func minValue(a, b Genotype, k string) (string, error) {
+	x, ok := a.values[k]
+	if !ok {
+		return "", nil
+	}
+	y, ok := b.values[k]
+	if !ok || y == "." {
+		return x, nil
+	}
+	if x == "." {
+		return y, nil
+	}
+	i, err := a.AttributeAsInt(k)
+	if err != nil {
+		return "", a.formatError(k, err)
+	}
+	j, err := b.AttributeAsInt(k)
+	if err != nil {
+		return "", b.formatError(k, err)
+	}
+	if j < i {
+		return y, nil
+	}
+	return x, nil
+}
+
+// Variant returns the most recent record read by Scan.
+func (b *BlockBander) Variant() Variant {
+	return b.token
+}
+
+// Err returns the first error encountered by the BlockBander or its
+// VariantScanner.
+func (b *BlockBander) Err() error {
+	if b.err != nil {
+		return b.err
+	}
+	return b.s.Err()
+}
+
+// GVCFConverter converts a gVCF to a VCF, dropping the reference blocks and
+// removing the <NON_REF> or <*> allele from the other records. INFO and
+// FORMAT fields with Number=A, R or G are subset to the remaining alleles
+// (see Variant.Split).
+type GVCFConverter struct {
+	s     VariantScanner
+	token Variant
+	err   error
+}
+
+// NewGVCFConverter creates a GVCFConverter that reads variants from s.
+func NewGVCFConverter(s VariantScanner) *GVCFConverter {
+	return &GVCFConverter{s: s}
+}
+
+// Scan advances to the next variant, which is then available from the
+// Variant method. It returns false when there are no more variants or an
+// error occurs.
+func (c *GVCFConverter) Scan() bool {
+	if c.err != nil {
+		return false
+	}
+	for c.s.Scan() {
+		v := c.s.Variant()
+		if v.IsRefBlock() {
+			continue
+		}
+		alleleMap := []int{0}
+		for i, a := range v.Alt {
+			if !stringSliceContains(nonRefAlleles, a) {
+				alleleMap = append(alleleMap, i+1)
+			}
+		}
+		if len(alleleMap) <= len(v.Alt) {
+			x, err := v.subsetAlleles(alleleMap)
+			if err != nil {
+				c.err = err
+				return false
+			}
+			v = x
+		}
+		c.token = v
+		return true
+	}
+	return false
+}
+
+// Variant returns the most recent variant read by Scan.
+func (c *GVCFConverter) Variant() Variant {
+	return c.token
+}
+
+// Err returns the first error encountered by the GVCFConverter or its
+// VariantScanner.
+func (c *GVCFConverter) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.s.Err()
+}
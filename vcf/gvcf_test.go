@@ -0,0 +1,170 @@
+package vcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+const gvcfTestVCF = `##fileformat=VCFv4.2
+##ALT=<ID=NON_REF,Description="Represents any possible alternative allele at this location">
+##INFO=<ID=END,Number=1,Type=Integer,Description="Stop position of the interval">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Approximate read depth">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Read depth">
+##FORMAT=<ID=GQ,Number=1,Type=Integer,Description="Genotype quality">
+##FORMAT=<ID=MIN_DP,Number=1,Type=Integer,Description="Minimum DP observed within the block">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled genotype likelihoods">
+##contig=<ID=1,length=1000>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+1	1	.	A	<NON_REF>	.	.	END=3	GT:DP:GQ:MIN_DP:PL	0/0:10:15:8:0,15,200
+1	4	.	C	<NON_REF>	.	.	END=5	GT:DP:GQ:MIN_DP:PL	0/0:12:12:11:0,12,180
+1	6	.	G	<NON_REF>	.	.	END=6	GT:DP:GQ:MIN_DP:PL	0/0:30:40:30:0,40,400
+1	7	.	T	C,<NON_REF>	50	.	DP=20	GT:AD:DP:GQ:PL	0/1:10,10,0:20:50:50,0,60,80,90,200
+1	8	.	A	<NON_REF>	.	.	END=10	GT:DP:GQ:MIN_DP:PL	0/0:25:45:20:0,45,450
+1	11	.	C	<NON_REF>	.	.	END=12	GT:DP:GQ:MIN_DP:PL	./.:0:0:0:0,0,0
+`
+
+// gvcfTestScanner returns a Scanner for gvcfTestVCF.
+func gvcfTestScanner(t *testing.T) *Scanner {
+	t.Helper()
+	v, err := New(writeTestFile(t, "test.g.vcf", gvcfTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// gvcfRecords returns the position, REF, ALT, END and sample of each
+// variant read from s.
+func gvcfRecords(t *testing.T, s VariantScanner) []string {
+	t.Helper()
+	xs := []string{}
+	for s.Scan() {
+		v := s.Variant()
+		g := v.Genotypes()[0]
+		x := v.Ref + ">" + v.Alt[0]
+		if end, ok := v.Info["END"]; ok {
+			x += " END=" + end
+		}
+		xs = append(xs, x+" "+g.AsVCFString())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return xs
+}
+
+func TestVariant_IsRefBlock(t *testing.T) {
+	tests := []struct {
+		alt  []string
+		want bool
+	}{
+		{[]string{"<NON_REF>"}, true},
+		{[]string{"<*>"}, true},
+		{[]string{"C", "<NON_REF>"}, false},
+		{[]string{"C"}, false},
+		{[]string{"<DEL>"}, false},
+	}
+	for _, tt := range tests {
+		v := Variant{Chrom: "1", Pos: 1, Ref: "A", Alt: tt.alt}
+		if got := v.IsRefBlock(); got != tt.want {
+			t.Errorf("Variant{Alt: %v}.IsRefBlock() = %v, want %v", tt.alt, got, tt.want)
+		}
+	}
+}
+
+func TestQueryGVCF(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.g.vcf", gvcfTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		pos     int
+		wantPos []int
+		wantDP  []int
+	}{
+		{2, []int{1}, []int{10}},
+		{5, []int{4}, []int{12}},
+		{7, []int{7}, []int{20}},
+		{9, []int{8}, []int{25}},
+		{13, nil, nil},
+	}
+	for _, tt := range tests {
+		xs, err := QueryGVCF(v, "1", tt.pos)
+		if err != nil {
+			t.Fatalf("QueryGVCF() error = %v", err)
+		}
+		var pos, dp []int
+		for _, x := range xs {
+			pos = append(pos, x.Pos)
+			n, err := x.Genotypes()[0].AttributeAsInt("DP")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dp = append(dp, n)
+		}
+		if !reflect.DeepEqual(pos, tt.wantPos) || !reflect.DeepEqual(dp, tt.wantDP) {
+			t.Errorf("QueryGVCF(1:%d) = %v with DP %v, want %v with DP %v", tt.pos, pos, dp, tt.wantPos, tt.wantDP)
+		}
+	}
+}
+
+func TestBlockExpander(t *testing.T) {
+	ref := testReference{"1": "ACGCTGTACGCA"}
+	got := gvcfRecords(t, NewBlockExpander(gvcfTestScanner(t), ref))
+	want := []string{
+		"A><NON_REF> 0/0:10:15:8:0,15,200",
+		"C><NON_REF> 0/0:10:15:8:0,15,200",
+		"G><NON_REF> 0/0:10:15:8:0,15,200",
+		"C><NON_REF> 0/0:12:12:11:0,12,180",
+		"T><NON_REF> 0/0:12:12:11:0,12,180",
+		"G><NON_REF> 0/0:30:40:30:0,40,400",
+		"T>C 0/1:10,10,0:20:50:50,0,60,80,90,200",
+		"A><NON_REF> 0/0:25:45:20:0,45,450",
+		"C><NON_REF> 0/0:25:45:20:0,45,450",
+		"G><NON_REF> 0/0:25:45:20:0,45,450",
+		"C><NON_REF> ./.:0:0:0:0,0,0",
+		"A><NON_REF> ./.:0:0:0:0,0,0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BlockExpander records = %q, want %q", got, want)
+	}
+}
+
+func TestBlockBander(t *testing.T) {
+	got := gvcfRecords(t, NewBlockBander(gvcfTestScanner(t), 20, 0))
+	want := []string{
+		"A><NON_REF> END=5 0/0:10:12:8:0,15,200",
+		"G><NON_REF> END=6 0/0:30:40:30:0,40,400",
+		"T>C 0/1:10,10,0:20:50:50,0,60,80,90,200",
+		"A><NON_REF> END=10 0/0:25:45:20:0,45,450",
+		"C><NON_REF> END=12 ./.:0:0:0:0,0,0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BlockBander records = %q, want %q", got, want)
+	}
+	got = gvcfRecords(t, NewBlockBander(gvcfTestScanner(t), 0, 60))
+	want = []string{
+		"A><NON_REF> END=6 0/0:10:12:8:0,15,200",
+		"T>C 0/1:10,10,0:20:50:50,0,60,80,90,200",
+		"A><NON_REF> END=10 0/0:25:45:20:0,45,450",
+		"C><NON_REF> END=12 ./.:0:0:0:0,0,0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BlockBander records = %q, want %q", got, want)
+	}
+}
+
+func TestGVCFConverter(t *testing.T) {
+	got := gvcfRecords(t, NewGVCFConverter(gvcfTestScanner(t)))
+	want := []string{"T>C 0/1:10,10:20:50:50,0,60"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GVCFConverter records = %q, want %q", got, want)
+	}
+}
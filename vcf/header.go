@@ -2,12 +2,12 @@ package vcf
 
 import (
 	"bufio"
-	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -34,6 +34,16 @@ func (h Header) Version() float64 {
 	return h.version
 }
 
+// SetVersion sets the VCF file format version, which must be 4.0 to 4.4.
+// The version is written by Writer.WriteHeader.
+func (h *Header) SetVersion(version float64) error {
+	if version < 4.0 || version > 4.4 {
+		return fmt.Errorf("unsupported VCF version %.1f", version)
+	}
+	h.version = version
+	return nil
+}
+
 // AddHeaderLines add the header lines to the header.
 func (h *Header) AddHeaderLines(lines ...HeaderLine) {
 	h.lines = append(h.lines, lines...)
@@ -74,40 +84,206 @@ func (h Header) Contigs() []HeaderLine {
 	return h.allHeaderLines("contig")
 }
 
-// Others returns all non- FILTER, INFO, FORMAT and contig header lines.
+// Alts returns all ALT header lines, which define symbolic alternate
+// alleles.
+func (h Header) Alts() []HeaderLine {
+	return h.allHeaderLines("ALT")
+}
+
+// Metas returns all META header lines, which define the values allowed in
+// SAMPLE header lines (VCF 4.3).
+func (h Header) Metas() []HeaderLine {
+	return h.allHeaderLines("META")
+}
+
+// SampleLines returns all SAMPLE header lines.
+func (h Header) SampleLines() []HeaderLine {
+	return h.allHeaderLines("SAMPLE")
+}
+
+// Pedigrees returns all PEDIGREE header lines.
+func (h Header) Pedigrees() []HeaderLine {
+	return h.allHeaderLines("PEDIGREE")
+}
+
+// structuredKeys are the keys of the header lines that have their own
+// accessors.
+var structuredKeys = []string{"fileformat", "FILTER", "INFO", "FORMAT", "ALT", "META", "SAMPLE", "PEDIGREE", "contig"}
+
+// Others returns all header lines other than the fileformat, FILTER, INFO,
+// FORMAT, ALT, META, SAMPLE, PEDIGREE and contig lines.
 func (h Header) Others() []HeaderLine {
 	xs := []HeaderLine{}
 	for _, l := range h.lines {
-		if l.Key != "FILTER" && l.Key != "INFO" && l.Key != "FORMAT" && l.Key != "contig" {
+		if !stringSliceContains(structuredKeys, l.Key) {
 			xs = append(xs, l)
 		}
 	}
 	return xs
 }
 
-// func parseHeader(r io.Reader) (Header, error)
+// SamplePolicy controls how Header.Merge combines the samples of two
+// headers.
+type SamplePolicy int
+
+const (
+	// UnionSamples adds the samples of the other header that are not
+	// already in the header.
+	UnionSamples SamplePolicy = iota
+	// DistinctSamples adds the samples of the other header, which must not
+	// have any samples in common with the header, for example, when
+	// merging the VCFs of different samples.
+	DistinctSamples
+	// IdenticalSamples requires both headers to have the same samples in
+	// the same order, for example, when concatenating VCFs.
+	IdenticalSamples
+)
+
+// MergeError is returned by Header.Merge and lists every conflict between
+// the headers.
+type MergeError struct {
+	Conflicts []string
+}
+
+func (e *MergeError) Error() string {
+	return fmt.Sprintf("unable to merge headers: %s", strings.Join(e.Conflicts, "; "))
+}
+
+// Merge adds the header lines and samples of other to h. Lines with an ID
+// (for example, INFO, FORMAT, FILTER and contig lines) are added once for
+// each Key and ID, keeping the first definition, and other lines are added
+// unless h has an identical line. The version is the later of the two. An
+// INFO or FORMAT field defined with a different Number or Type, a contig
+// with a different length, or samples that break policy are conflicts: h is
+// left unchanged and a *MergeError listing all of them is returned.
+func (h *Header) Merge(other Header, policy SamplePolicy) error {
+	var conflicts []string
+	lines := append([]HeaderLine{}, h.lines...)
+	defined := make(map[string]HeaderLine)
+	seen := make(map[string]bool)
+	for _, l := range h.lines {
+		if l.Value == "" && l.ID() != "" {
+			defined[l.Key+"/"+l.ID()] = l
+		} else {
+			seen[l.AsVCFString()] = true
+		}
+	}
+	for _, l := range other.lines {
+		if l.Key == "fileformat" {
+			continue
+		}
+		if l.Value != "" || l.ID() == "" {
+			if s := l.AsVCFString(); !seen[s] {
+				seen[s] = true
+				lines = append(lines, l)
+			}
+			continue
+		}
+		key := l.Key + "/" + l.ID()
+		d, ok := defined[key]
+		if !ok {
+			defined[key] = l
+			lines = append(lines, l)
+			continue
+		}
+		conflicts = append(conflicts, lineConflicts(d, l)...)
+	}
+	samples := append([]string{}, h.Samples...)
+	switch policy {
+	case UnionSamples:
+		for _, s := range other.Samples {
+			if !stringSliceContains(samples, s) {
+				samples = append(samples, s)
+			}
+		}
+	case DistinctSamples:
+		for _, s := range other.Samples {
+			if stringSliceContains(samples, s) {
+				conflicts = append(conflicts, fmt.Sprintf("sample %s is in both headers", s))
+			}
+			samples = append(samples, s)
+		}
+	case IdenticalSamples:
+		if !stringSliceEqual(h.Samples, other.Samples) {
+			conflicts = append(conflicts, fmt.Sprintf("headers do not have the same samples: %s and %s", strings.Join(h.Samples, ","), strings.Join(other.Samples, ",")))
+		}
+	}
+	if len(conflicts) > 0 {
+		return &MergeError{Conflicts: conflicts}
+	}
+	if other.version > h.version {
+		h.version = other.version
+		for i, l := range lines {
+			if l.Key == "fileformat" {
+				lines[i].Value = fmt.Sprintf("VCFv%2.1f", h.version)
+			}
+		}
+	}
+	h.lines = lines
+	h.Samples = samples
+	return nil
+}
+
+// lineConflicts returns the differences between two header lines with the
+// same key and ID that make them incompatible.
+func lineConflicts(a, b HeaderLine) []string {
+	var tags []string
+	switch a.Key {
+	case "INFO", "FORMAT":
+		tags = []string{"Number", "Type"}
+	case "contig":
+		tags = []string{"length"}
+	}
+	var xs []string
+	for _, t := range tags {
+		x, y := a.Get(t), b.Get(t)
+		if x != y && x != "" && y != "" {
+			xs = append(xs, fmt.Sprintf("%s %s is defined with %s=%s and %s=%s", a.Key, a.ID(), t, x, t, y))
+		}
+	}
+	return xs
+}
+
 // func parseHeaderFromStringSlice(headerLines []string) (Header, error)
 func readHeaderFromFile(path string) (Header, error) {
 	if _, err := os.Stat(path); err != nil {
 		return Header{}, fmt.Errorf("can not stat file: %w", err)
 	}
-	exe, err := findBcftools()
+	f, err := openFile(path)
 	if err != nil {
 		return Header{}, err
 	}
-	cmd := exec.Command(exe, "view", "--no-version", "-h", path)
-	bs, err := cmd.Output()
-	if err != nil {
-		return Header{}, fmt.Errorf("process failed: %v", err)
+	defer f.Close()
+	if f.isBCF() {
+		return readBCFHeader(f)
 	}
+	return readHeader(f.Reader)
+}
+
+// readHeader reads and parses the header lines from r, stopping after the
+// #CHROM line. Any data after the header is left unread in r.
+func readHeader(r *bufio.Reader) (Header, error) {
 	headerLines := []string{}
-	scanner := bufio.NewScanner(bytes.NewReader(bs))
-	for scanner.Scan() {
-		line := scanner.Text()
+	for {
+		b, err := r.Peek(1)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Header{}, fmt.Errorf("reading header failed: %w", err)
+		}
+		if b[0] != headerIndicator[0] {
+			break
+		}
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return Header{}, fmt.Errorf("reading header failed: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
 		headerLines = append(headerLines, line)
-	}
-	if err := scanner.Err(); err != nil {
-		return Header{}, fmt.Errorf("scanning header failed: %v", err)
+		if strings.HasPrefix(line, "#CHROM") {
+			break
+		}
 	}
 	return parseHeader(headerLines)
 }
@@ -156,6 +332,18 @@ type HeaderLine struct {
 	Key     string
 	Value   string
 	mapping map[string]string
+	// attrs holds the order and quoting of the attributes of a parsed line.
+	attrs []headerAttr
+}
+
+// headerAttr is an attribute of a structured header line.
+type headerAttr struct {
+	key    string
+	quoted bool
+	// value and raw are the parsed value of a quoted attribute and the
+	// text between its quotes, which is written back unchanged while the
+	// value is unchanged.
+	value, raw string
 }
 
 // ID returns the ID tag from the header line. If the line has no ID tag an
@@ -169,56 +357,80 @@ func (h HeaderLine) Get(id string) string {
 }
 
 // AsVCFString returns the header line in the format expected in a VCF header.
+// The attributes of a parsed line are written in their original order and
+// quoting, so that reading and writing a header does not change it.
 func (h HeaderLine) AsVCFString() string {
-	// The VCF specification is vague on whether the tags in a header line
-	// should appear in any particular order or what that order might be.
-	// This is problematic for testing as the tags are stored in a map and
-	// may appear in any order. Is there a single correct way to write every
-	// header or is it always ambiguous?
-	if h.Value == "" {
-		// if h.Key == "SAMPLE" {
-		// 	s, _ := sampleHeaderAsVCFString(h)
-		// 	return s
-		// }
-		var builder strings.Builder
-		builder.WriteString(fmt.Sprintf("##%s=<", h.Key))
-		pairs := []string{}
-		id, ok := h.mapping["ID"]
-		if ok {
-			pairs = append(pairs, fmt.Sprintf("ID=%s", id))
-		}
-		number, ok := h.mapping["Number"]
-		if ok {
-			pairs = append(pairs, fmt.Sprintf("Number=%s", number))
+	if h.Value != "" || h.mapping == nil {
+		return fmt.Sprintf("##%s=%s", h.Key, h.Value)
+	}
+	pairs := []string{}
+	for _, a := range h.attributes() {
+		v := h.mapping[a.key]
+		if a.quoted && a.raw != "" && v == a.value {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, a.key, a.raw))
+		} else if a.quoted {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, a.key, escapeQuotes(v)))
+		} else {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", a.key, v))
 		}
-		_type, ok := h.mapping["Type"]
-		if ok {
-			pairs = append(pairs, fmt.Sprintf("Type=%s", _type))
+	}
+	return fmt.Sprintf("##%s=<%s>", h.Key, strings.Join(pairs, ","))
+}
+
+// attributeOrder is the order of the attributes of header lines that were
+// not parsed, such as those created with NewComplexHeaderLine. Other
+// attributes follow in alphabetical order.
+var attributeOrder = []string{"ID", "Number", "Type", "Description", "length"}
+
+// attributes returns the attributes of h in the order they were parsed,
+// followed by any attributes that were not parsed.
+func (h HeaderLine) attributes() []headerAttr {
+	xs := []headerAttr{}
+	seen := make(map[string]bool, len(h.mapping))
+	for _, a := range h.attrs {
+		if _, ok := h.mapping[a.key]; ok && !seen[a.key] {
+			seen[a.key] = true
+			xs = append(xs, a)
 		}
-		desc, ok := h.mapping["Description"]
-		if ok {
-			pairs = append(pairs, fmt.Sprintf(`Description="%s"`, desc))
+	}
+	rest := []string{}
+	for k := range h.mapping {
+		if !seen[k] && !stringSliceContains(attributeOrder, k) {
+			rest = append(rest, k)
 		}
-		// It's not specified what order, if any, extra tags should be in.
-		for k, v := range h.mapping {
-			if k != "ID" && k != "Number" && k != "Type" && k != "Description" {
-				// It is not specified in the spec whether
-				// contig field values should be quoted or not,
-				// but all files I have access to do not quote
-				// the values.
-				if h.Key == "contig" {
-					// Should not be quoted
-					pairs = append(pairs, fmt.Sprintf(`%s=%s`, k, v))
-				} else {
-					pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, v))
-				}
-			}
+	}
+	sort.Strings(rest)
+	for _, k := range append(append([]string{}, attributeOrder...), rest...) {
+		v, ok := h.mapping[k]
+		if !ok || seen[k] {
+			continue
 		}
-		builder.WriteString(strings.Join(pairs, ","))
-		builder.WriteString(">")
-		return builder.String()
+		// It is not specified in the spec whether contig field values
+		// should be quoted or not, but all files I have access to do
+		// not quote the values.
+		quoted := k == "Description" || (h.Key != "contig" && needsQuotes(k, v))
+		xs = append(xs, headerAttr{key: k, quoted: quoted})
+	}
+	return xs
+}
+
+// needsQuotes returns true if the value of the tag k should be quoted. The
+// specification requires Source and Version to be quoted, other values are
+// quoted if they contain characters that would otherwise end them. Lists in
+// square brackets, such as the Values of META lines, are not quoted.
+func needsQuotes(k, v string) bool {
+	if k == "Source" || k == "Version" {
+		return true
+	}
+	if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+		return false
 	}
-	return fmt.Sprintf("##%s=%s", h.Key, h.Value)
+	return v == "" || strings.ContainsAny(v, ",<>=\" \t")
+}
+
+// escapeQuotes escapes quotes and backslashes in a quoted value.
+func escapeQuotes(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v)
 }
 
 // the examples in v4.3 specs use completely different tags (Assay, Ethnicity
@@ -265,12 +477,13 @@ func parseHeaderLine(s string) (HeaderLine, error) {
 	headerKey := match[1]
 	value := ""
 	var mapping map[string]string
+	var attrs []headerAttr
 	if strings.Contains(s, "<") {
 		var err error
 		if !strings.Contains(s, ">") {
 			return HeaderLine{}, fmt.Errorf("header line is malformed: %s", s)
 		}
-		mapping, err = parseValues(s)
+		mapping, attrs, err = parseValues(s)
 		if err != nil {
 			return HeaderLine{}, fmt.Errorf("failed to parse header line: %w", err)
 		}
@@ -280,6 +493,10 @@ func parseHeaderLine(s string) (HeaderLine, error) {
 			if !ok {
 				return HeaderLine{}, fmt.Errorf("%s header line must contain an ID tag", headerKey)
 			}
+		case "META", "SAMPLE":
+			if _, ok := mapping["ID"]; !ok {
+				return HeaderLine{}, fmt.Errorf("%s header line must contain an ID tag", headerKey)
+			}
 		case "FILTER", "ALT":
 			err := checkTags(mapping, []string{"ID", "Description"})
 			if err != nil {
@@ -294,7 +511,7 @@ func parseHeaderLine(s string) (HeaderLine, error) {
 	} else {
 		value = strings.SplitN(s, "=", 2)[1]
 	}
-	return HeaderLine{headerKey, value, mapping}, nil
+	return HeaderLine{Key: headerKey, Value: value, mapping: mapping, attrs: attrs}, nil
 }
 
 func checkTags(mapping map[string]string, requiredTags []string) error {
@@ -307,24 +524,39 @@ func checkTags(mapping map[string]string, requiredTags []string) error {
 	return nil
 }
 
-// A little switch machine to parse out the tags: thanks htsjdk team!
-func parseValues(s string) (map[string]string, error) {
-	var builder strings.Builder
+// A little switch machine to parse out the tags: thanks htsjdk team! The
+// tags are also returned in order, noting which were quoted.
+func parseValues(s string) (map[string]string, []headerAttr, error) {
+	var builder, raw strings.Builder
 	ret := make(map[string]string)
+	attrs := []headerAttr{}
 	key := ""
 	index := 0
 	inQuote := false
+	inBrackets := false
+	quoted := false
 	escape := false
+	add := func() {
+		if _, ok := ret[key]; !ok {
+			attrs = append(attrs, headerAttr{key, quoted, builder.String(), raw.String()})
+		}
+		ret[key] = builder.String()
+		builder = strings.Builder{}
+		raw = strings.Builder{}
+	}
 
 	for _, c := range s {
 		if c == '"' {
 			if escape {
 				_, _ = builder.WriteRune(c)
+				raw.WriteRune(c)
 				escape = false
 			} else {
 				inQuote = !inQuote
+				quoted = true
 			}
 		} else if inQuote {
+			raw.WriteRune(c)
 			if escape {
 				if c == '\\' {
 					builder.WriteRune(c)
@@ -347,15 +579,27 @@ func parseValues(s string) (map[string]string, error) {
 				}
 			case '>':
 				if index == len(s)-1 {
-					ret[key] = builder.String()
+					add()
 					break
 				}
+			case '[', ']':
+				// Lists in brackets may contain commas.
+				inBrackets = c == '['
+				builder.WriteRune(c)
 			case '=':
+				if inBrackets {
+					builder.WriteRune(c)
+					break
+				}
 				key = builder.String()
 				builder = strings.Builder{}
+				quoted = false
 			case ',':
-				ret[key] = builder.String()
-				builder = strings.Builder{}
+				if inBrackets {
+					builder.WriteRune(c)
+					break
+				}
+				add()
 			default:
 				builder.WriteRune(c)
 			}
@@ -363,9 +607,9 @@ func parseValues(s string) (map[string]string, error) {
 		index++
 	}
 	if inQuote {
-		return make(map[string]string), errors.New("unclosed quote in header line")
+		return make(map[string]string), nil, errors.New("unclosed quote in header line")
 	}
-	return ret, nil
+	return ret, attrs, nil
 }
 
 // StandardHeaderLines returns a slice of standard VCF headers.
@@ -1,7 +1,11 @@
 package vcf
 
 import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -73,13 +77,13 @@ func Test_parseHeaderLine(t *testing.T) {
 		{"t2", args{"##filedate=20151210"}, HeaderLine{Key: "filedate", Value: "20151210"}, false},
 		{"t3", args{`##source="simplfy-vcf (r1211)"`}, HeaderLine{Key: "source", Value: `"simplfy-vcf (r1211)"`}, false},
 		{"t4", args{"##foobar"}, HeaderLine{}, true},
-		{"t1", args{"##contig=<ID=1,length=249250621,assembly=b37>"}, HeaderLine{"contig", "", map[string]string{"ID": "1", "length": "249250621", "assembly": "b37"}}, false},
-		{"t2", args{"##contig=<ID=GL000207.1,length=4262,assembly=b37>"}, HeaderLine{"contig", "", map[string]string{"ID": "GL000207.1", "length": "4262", "assembly": "b37"}}, false},
-		{"t3", args{"##contig=<ID=1,length=249250621>"}, HeaderLine{"contig", "", map[string]string{"ID": "1", "length": "249250621"}}, false},
-		{"t4", args{"##contig=<ID=1>"}, HeaderLine{"contig", "", map[string]string{"ID": "1"}}, false},
+		{"t1", args{"##contig=<ID=1,length=249250621,assembly=b37>"}, HeaderLine{Key: "contig", mapping: map[string]string{"ID": "1", "length": "249250621", "assembly": "b37"}}, false},
+		{"t2", args{"##contig=<ID=GL000207.1,length=4262,assembly=b37>"}, HeaderLine{Key: "contig", mapping: map[string]string{"ID": "GL000207.1", "length": "4262", "assembly": "b37"}}, false},
+		{"t3", args{"##contig=<ID=1,length=249250621>"}, HeaderLine{Key: "contig", mapping: map[string]string{"ID": "1", "length": "249250621"}}, false},
+		{"t4", args{"##contig=<ID=1>"}, HeaderLine{Key: "contig", mapping: map[string]string{"ID": "1"}}, false},
 		{"t5", args{"##contig=<length=249250621>"}, HeaderLine{}, true},
-		{"t6", args{`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`}, HeaderLine{"FORMAT", "", map[string]string{"ID": "GT", "Number": "1", "Type": "String", "Description": "Genotype"}}, false},
-		{"t7", args{`##FORMAT=<ID=GQ,Number=1,Type=Integer,Description="Minimum GenCall score, encoded as a phred quality integer.",Source="description",Version="128">`}, HeaderLine{"FORMAT", "", map[string]string{"ID": "GQ", "Number": "1", "Type": "Integer", "Description": "Minimum GenCall score, encoded as a phred quality integer.", "Source": "description", "Version": "128"}}, false},
+		{"t6", args{`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`}, HeaderLine{Key: "FORMAT", mapping: map[string]string{"ID": "GT", "Number": "1", "Type": "String", "Description": "Genotype"}}, false},
+		{"t7", args{`##FORMAT=<ID=GQ,Number=1,Type=Integer,Description="Minimum GenCall score, encoded as a phred quality integer.",Source="description",Version="128">`}, HeaderLine{Key: "FORMAT", mapping: map[string]string{"ID": "GQ", "Number": "1", "Type": "Integer", "Description": "Minimum GenCall score, encoded as a phred quality integer.", "Source": "description", "Version": "128"}}, false},
 		// Missing ID
 		{"t8", args{`##FORMAT=<Number=1,Type=String,Description="Genotype">`}, HeaderLine{}, true},
 		// Missing Number
@@ -90,30 +94,29 @@ func Test_parseHeaderLine(t *testing.T) {
 		{"t11", args{`##FORMAT=<ID=GT,Number=1,Type=String,>`}, HeaderLine{}, true},
 		// Source not quoted
 		// {"t12", args{`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype",Source=description>`}, HeaderLine{}, true},
-		{"t13", args{`##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count in genotypes">`}, HeaderLine{"INFO", "", map[string]string{"ID": "AC", "Number": "A", "Type": "Integer", "Description": "Allele count in genotypes"}}, false},
-		{"t13", args{`##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count in genotypes",Source="description",Version="128">`}, HeaderLine{"INFO", "", map[string]string{"ID": "AC", "Number": "A", "Type": "Integer", "Description": "Allele count in genotypes", "Source": "description", "Version": "128"}}, false},
+		{"t13", args{`##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count in genotypes">`}, HeaderLine{Key: "INFO", mapping: map[string]string{"ID": "AC", "Number": "A", "Type": "Integer", "Description": "Allele count in genotypes"}}, false},
+		{"t13", args{`##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count in genotypes",Source="description",Version="128">`}, HeaderLine{Key: "INFO", mapping: map[string]string{"ID": "AC", "Number": "A", "Type": "Integer", "Description": "Allele count in genotypes", "Source": "description", "Version": "128"}}, false},
 		{"t14", args{`##INFO=<Number=A,Type=Integer,Description="Allele count in genotypes">`}, HeaderLine{}, true},
 		{"t15", args{`##INFO=<ID=AC,Type=Integer,Description="Allele count in genotypes">`}, HeaderLine{}, true},
 		{"t16", args{`##INFO=<ID=AC,Number=A,Description="Allele count in genotypes">`}, HeaderLine{}, true},
 		{"t17", args{`##INFO=<ID=AC,Number=A,Type=Integer>`}, HeaderLine{}, true},
-		{"t18", args{`##FILTER=<ID=LowQual,Description="Low quality">`}, HeaderLine{"FILTER", "", map[string]string{"ID": "LowQual", "Description": "Low quality"}}, false},
-		{"t19", args{`##FILTER=<ID=LowQual,Description="Low quality",Source="description",Version="128">`}, HeaderLine{"FILTER", "", map[string]string{"ID": "LowQual", "Description": "Low quality", "Source": "description", "Version": "128"}}, false},
+		{"t18", args{`##FILTER=<ID=LowQual,Description="Low quality">`}, HeaderLine{Key: "FILTER", mapping: map[string]string{"ID": "LowQual", "Description": "Low quality"}}, false},
+		{"t19", args{`##FILTER=<ID=LowQual,Description="Low quality",Source="description",Version="128">`}, HeaderLine{Key: "FILTER", mapping: map[string]string{"ID": "LowQual", "Description": "Low quality", "Source": "description", "Version": "128"}}, false},
 		{"t20", args{`##FILTER=<Description="Low quality">`}, HeaderLine{}, true},
 		{"t21", args{`##FILTER=<ID=LowQual>`}, HeaderLine{}, true},
 		// ALT, SAMPLE, PEDIGREE
 		// listed in specs DEL INS DUP INV CNV DUP:TANDEM DEL:ME INS:ME (exclusive?)
-		{"t22", args{`##ALT=<ID=DEL,Description="description">`}, HeaderLine{"ALT", "", map[string]string{"ID": "DEL", "Description": "description"}}, false},
-		{"t23", args{`##ALT=<ID=DEL,Description="description",Source="description",Version="128">`}, HeaderLine{"ALT", "", map[string]string{"ID": "DEL", "Description": "description", "Source": "description", "Version": "128"}}, false},
+		{"t22", args{`##ALT=<ID=DEL,Description="description">`}, HeaderLine{Key: "ALT", mapping: map[string]string{"ID": "DEL", "Description": "description"}}, false},
+		{"t23", args{`##ALT=<ID=DEL,Description="description",Source="description",Version="128">`}, HeaderLine{Key: "ALT", mapping: map[string]string{"ID": "DEL", "Description": "description", "Source": "description", "Version": "128"}}, false},
 		{"t24", args{`##ALT=<Description="description">`}, HeaderLine{}, true},
 		{"t25", args{`##ALT=<ID=DEL>`}, HeaderLine{}, true},
-		{"t26", args{`##SAMPLE=<ID=Blood,Genomes=Germline,Mixture=1.,Description="Patient germline genome">`}, HeaderLine{"SAMPLE", "", map[string]string{"ID": "Blood", "Genomes": "Germline", "Mixture": "1.", "Description": "Patient germline genome"}}, false},
+		{"t26", args{`##SAMPLE=<ID=Blood,Genomes=Germline,Mixture=1.,Description="Patient germline genome">`}, HeaderLine{Key: "SAMPLE", mapping: map[string]string{"ID": "Blood", "Genomes": "Germline", "Mixture": "1.", "Description": "Patient germline genome"}}, false},
 		{
 			"t27",
 			args{`##SAMPLE=<ID=TissueSample,Genomes=Germline;Tumor,Mixture=.3;.7,Description="Patient germline genome;Patient tumor genome">`},
 			HeaderLine{
-				"SAMPLE",
-				"",
-				map[string]string{
+				Key: "SAMPLE",
+				mapping: map[string]string{
 					"ID":          "TissueSample",
 					"Genomes":     "Germline;Tumor",
 					"Mixture":     ".3;.7",
@@ -122,6 +125,14 @@ func Test_parseHeaderLine(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"t28",
+			args{`##META=<ID=Assay,Type=String,Number=.,Values=[WholeGenome, Exome]>`},
+			HeaderLine{Key: "META", mapping: map[string]string{"ID": "Assay", "Type": "String", "Number": ".", "Values": "[WholeGenome, Exome]"}},
+			false,
+		},
+		{"t29", args{`##META=<Type=String,Number=.,Values=[WholeGenome, Exome]>`}, HeaderLine{}, true},
+		{"t30", args{`##PEDIGREE=<ID=TumourSample,Original=GermlineID>`}, HeaderLine{Key: "PEDIGREE", mapping: map[string]string{"ID": "TumourSample", "Original": "GermlineID"}}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -130,6 +141,13 @@ func Test_parseHeaderLine(t *testing.T) {
 				t.Errorf("parseHeaderLine() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if err == nil {
+				// The order and quoting of the attributes are kept.
+				if s := got.AsVCFString(); s != tt.args.s {
+					t.Errorf("parseHeaderLine().AsVCFString() = %v, want %v", s, tt.args.s)
+				}
+			}
+			got.attrs = nil
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("parseHeaderLine() = %v, want %v", got, tt.want)
 			}
@@ -151,20 +169,20 @@ func TestHeaderLine_AsVCFString(t *testing.T) {
 		{"t1", fields{Key: "bcftools_annotateVersion", Value: "1.9+htslib-1.9"}, "##bcftools_annotateVersion=1.9+htslib-1.9"},
 		{"t2", fields{Key: "filedate", Value: "20151210"}, "##filedate=20151210"},
 		{"t3", fields{Key: "source", Value: `"simplfy-vcf (r1211)"`}, `##source="simplfy-vcf (r1211)"`},
-		// For contig length and assembly are stored in map it will be
-		// purely coincidental if these tests pass.
+		// Attributes of lines that were not parsed are written in a
+		// fixed order.
 		{"t4", fields{"contig", "", map[string]string{"ID": "1", "length": "249250621", "assembly": "b37"}}, "##contig=<ID=1,length=249250621,assembly=b37>"},
 		{"t5", fields{"contig", "", map[string]string{"ID": "GL000207.1", "length": "4262", "assembly": "b37"}}, "##contig=<ID=GL000207.1,length=4262,assembly=b37>"},
 		{"t6", fields{"contig", "", map[string]string{"ID": "1", "length": "249250621"}}, "##contig=<ID=1,length=249250621>"},
 		{"t7", fields{"contig", "", map[string]string{"ID": "1"}}, "##contig=<ID=1>"},
 		{"t8", fields{"FORMAT", "", map[string]string{"ID": "GT", "Number": "1", "Type": "String", "Description": "Genotype"}}, `##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`},
-		// {"t9", fields{"FORMAT", "", map[string]string{"ID": "GQ", "Number": "1", "Type": "Integer", "Description": "Minimum GenCall score, encoded as a phred quality integer.", "Source": "description", "Version": "128"}}, `##FORMAT=<ID=GQ,Number=1,Type=Integer,Description="Minimum GenCall score, encoded as a phred quality integer.",Source="description",Version="128">`},
+		{"t9", fields{"FORMAT", "", map[string]string{"ID": "GQ", "Number": "1", "Type": "Integer", "Description": "Minimum GenCall score, encoded as a phred quality integer.", "Source": "description", "Version": "128"}}, `##FORMAT=<ID=GQ,Number=1,Type=Integer,Description="Minimum GenCall score, encoded as a phred quality integer.",Source="description",Version="128">`},
 		{"t10", fields{"INFO", "", map[string]string{"ID": "AC", "Number": "A", "Type": "Integer", "Description": "Allele count in genotypes"}}, `##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count in genotypes">`},
 		{"t11", fields{"INFO", "", map[string]string{"ID": "AC", "Number": "A", "Type": "Integer", "Description": "Allele count in genotypes", "Source": "description", "Version": "128"}}, `##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count in genotypes",Source="description",Version="128">`},
 		{"t12", fields{"FILTER", "", map[string]string{"ID": "LowQual", "Description": "Low quality"}}, `##FILTER=<ID=LowQual,Description="Low quality">`},
-		// {"t13", fields{"FILTER", "", map[string]string{"ID": "LowQual", "Description": "Low quality", "Source": "description", "Version": "128"}}, `##FILTER=<ID=LowQual,Description="Low quality",Source="description",Version="128">`},
+		{"t13", fields{"FILTER", "", map[string]string{"ID": "LowQual", "Description": "Low quality", "Source": "description", "Version": "128"}}, `##FILTER=<ID=LowQual,Description="Low quality",Source="description",Version="128">`},
 		{"t14", fields{"ALT", "", map[string]string{"ID": "DEL", "Description": "description"}}, `##ALT=<ID=DEL,Description="description">`},
-		// {"t15", fields{"ALT", "", map[string]string{"ID": "DEL", "Description": "description", "Source": "description", "Version": "128"}}, `##ALT=<ID=DEL,Description="description",Source="description",Version="128">`},
+		{"t15", fields{"ALT", "", map[string]string{"ID": "DEL", "Description": "description", "Source": "description", "Version": "128"}}, `##ALT=<ID=DEL,Description="description",Source="description",Version="128">`},
 		// SAMPLE the order of keys is not defined is specs
 		// {
 		// 	"t16",
@@ -208,3 +226,234 @@ func TestHeaderLine_AsVCFString(t *testing.T) {
 		})
 	}
 }
+
+func TestHeaderLine_AsVCFString_roundTrip(t *testing.T) {
+	tests := []string{
+		`##INFO=<ID=X,Number=1,Type=String,Description="Path C:\dir\n">`,
+		`##INFO=<ID=X,Number=1,Type=String,Description="A \"quoted\" word">`,
+		`##INFO=<ID=X,Number=1,Type=String,Description="An escaped \\ backslash">`,
+		`##INFO=<ID=X,Number=1,Type=String,Description="">`,
+	}
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			l, err := parseHeaderLine(tt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				got := l.AsVCFString()
+				if got != tt {
+					t.Fatalf("HeaderLine.AsVCFString() = %v, want %v", got, tt)
+				}
+				if l, err = parseHeaderLine(got); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestHeader_Merge(t *testing.T) {
+	parse := func(lines ...string) Header {
+		t.Helper()
+		h, err := parseHeader(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	a := parse(
+		"##fileformat=VCFv4.1",
+		`##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth">`,
+		`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`,
+		"##contig=<ID=1,length=100>",
+		"##source=a",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS1\tS2",
+	)
+	b := parse(
+		"##fileformat=VCFv4.2",
+		`##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">`,
+		`##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">`,
+		`##FILTER=<ID=q10,Description="Quality below 10">`,
+		"##contig=<ID=1,length=100>",
+		"##contig=<ID=2,length=200>",
+		"##source=a",
+		"##source=b",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS2\tS3",
+	)
+	tests := []struct {
+		name    string
+		other   Header
+		policy  SamplePolicy
+		lines   []string
+		samples []string
+		wantErr []string
+	}{
+		{
+			name:   "union",
+			other:  b,
+			policy: UnionSamples,
+			lines: []string{
+				"##fileformat=VCFv4.2",
+				`##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth">`,
+				`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`,
+				"##contig=<ID=1,length=100>",
+				"##source=a",
+				`##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">`,
+				`##FILTER=<ID=q10,Description="Quality below 10">`,
+				"##contig=<ID=2,length=200>",
+				"##source=b",
+			},
+			samples: []string{"S1", "S2", "S3"},
+		},
+		{
+			name:    "distinct",
+			other:   b,
+			policy:  DistinctSamples,
+			wantErr: []string{"sample S2 is in both headers"},
+		},
+		{
+			name:    "identical",
+			other:   b,
+			policy:  IdenticalSamples,
+			wantErr: []string{"headers do not have the same samples: S1,S2 and S2,S3"},
+		},
+		{
+			name: "conflicts",
+			other: parse(
+				"##fileformat=VCFv4.2",
+				`##INFO=<ID=DP,Number=A,Type=Float,Description="Depth">`,
+				`##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">`,
+				"##contig=<ID=1,length=101>",
+				"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS3",
+			),
+			policy: DistinctSamples,
+			wantErr: []string{
+				"INFO DP is defined with Number=1 and Number=A",
+				"INFO DP is defined with Type=Integer and Type=Float",
+				"contig 1 is defined with length=100 and length=101",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := a
+			err := h.Merge(tt.other, tt.policy)
+			if tt.wantErr != nil {
+				var merr *MergeError
+				if !errors.As(err, &merr) || !reflect.DeepEqual(merr.Conflicts, tt.wantErr) {
+					t.Fatalf("Header.Merge() error = %v, want conflicts %q", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(h, a) {
+					t.Errorf("Header.Merge() changed the header when it failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Header.Merge() error = %v", err)
+			}
+			got := []string{}
+			for _, l := range h.HeaderLines() {
+				got = append(got, l.AsVCFString())
+			}
+			if !reflect.DeepEqual(got, tt.lines) {
+				t.Errorf("Header.Merge() lines = %q, want %q", got, tt.lines)
+			}
+			if !reflect.DeepEqual(h.Samples, tt.samples) {
+				t.Errorf("Header.Merge() samples = %v, want %v", h.Samples, tt.samples)
+			}
+			if h.Version() != 4.2 {
+				t.Errorf("Header.Merge() version = %v, want 4.2", h.Version())
+			}
+			if len(a.HeaderLines()) != 5 || a.HeaderLines()[0].Value != "VCFv4.1" {
+				t.Errorf("Header.Merge() modified the lines of the original header")
+			}
+		})
+	}
+}
+
+const header44 = `##fileformat=VCFv4.4
+##source=test
+##contig=<ID=1,length=1000,assembly=b37>
+##FILTER=<ID=PASS,Description="All filters passed">
+##INFO=<ID=SVLEN,Number=A,Type=Integer,Description="Length of the SV",Version="1",Source="test">
+##FORMAT=<ID=PS,Number=P,Type=Integer,Description="Phase sets">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##ALT=<ID=DEL,Description="Deletion">
+##META=<ID=Assay,Type=String,Number=.,Values=[WholeGenome, Exome]>
+##SAMPLE=<ID=Blood,Assay="WholeGenome",Description="Patient \"germline\" genome">
+##PEDIGREE=<ID=Tumour,Original=Blood>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	Blood	Tumour
+`
+
+func TestHeader_v44(t *testing.T) {
+	s, err := NewReader(strings.NewReader(header44))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Header()
+	if h.Version() != 4.4 {
+		t.Errorf("Header.Version() = %v, want 4.4", h.Version())
+	}
+	keys := func(lines []HeaderLine) string {
+		xs := []string{}
+		for _, l := range lines {
+			xs = append(xs, l.Key+":"+l.ID()+l.Value)
+		}
+		return strings.Join(xs, " ")
+	}
+	tests := []struct {
+		name  string
+		lines []HeaderLine
+		want  string
+	}{
+		{"Alts", h.Alts(), "ALT:DEL"},
+		{"Metas", h.Metas(), "META:Assay"},
+		{"SampleLines", h.SampleLines(), "SAMPLE:Blood"},
+		{"Pedigrees", h.Pedigrees(), "PEDIGREE:Tumour"},
+		{"Others", h.Others(), "source:test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keys(tt.lines); got != tt.want {
+				t.Errorf("Header.%s() = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+	if got := h.Metas()[0].Get("Values"); got != "[WholeGenome, Exome]" {
+		t.Errorf("META Values = %q", got)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.vcf")
+	w, err := NewWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(h); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != header44 {
+		t.Errorf("written header =\n%s\nwant\n%s", b, header44)
+	}
+}
+
+func TestHeader_SetVersion(t *testing.T) {
+	h := NewHeader()
+	for _, v := range []float64{4.0, 4.3, 4.4} {
+		if err := h.SetVersion(v); err != nil || h.Version() != v {
+			t.Errorf("Header.SetVersion(%v) = %v, version %v", v, err, h.Version())
+		}
+	}
+	for _, v := range []float64{3.3, 4.5} {
+		if err := h.SetVersion(v); err == nil {
+			t.Errorf("Header.SetVersion(%v) expected error", v)
+		}
+	}
+}
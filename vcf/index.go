@@ -0,0 +1,431 @@
+package vcf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/biogo/hts/bgzf/index"
+	"github.com/biogo/hts/csi"
+	"github.com/biogo/hts/tabix"
+)
+
+// tabixMaxPos is the largest position that can be stored in a tabix index.
+const tabixMaxPos = 1 << 29
+
+// Index is a tabix (.tbi) or CSI (.csi) index of a BGZF compressed VCF. It
+// maps genomic regions to the BGZF virtual offsets of the records that may
+// overlap them.
+type Index struct {
+	tbi    *tabix.Index
+	csi    *csi.Index
+	ids    map[string]int
+	maxPos int
+}
+
+// OpenIndex opens the index of the VCF file path. It looks for path.tbi and
+// then path.csi, and returns an error satisfying errors.Is(err,
+// os.ErrNotExist) if neither exists.
+func OpenIndex(path string) (*Index, error) {
+	for _, ext := range []string{".tbi", ".csi"} {
+		f, err := os.Open(path + ext)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to open index: %w", err)
+		}
+		defer f.Close()
+		idx, err := ReadIndex(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read index %s: %w", path+ext, err)
+		}
+		return idx, nil
+	}
+	return nil, fmt.Errorf("no index found for %s: %w", path, os.ErrNotExist)
+}
+
+// ReadIndex reads a tabix or CSI index from r. The index may be BGZF
+// compressed, as it is when written by tabix or bcftools, or uncompressed.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+	bs, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(bs, []byte("TBI\x01")):
+		tbi, err := tabix.ReadFrom(bytes.NewReader(bs))
+		if err != nil {
+			return nil, err
+		}
+		if tbi == nil {
+			// An index of a file without any records.
+			tbi = tabix.New()
+		}
+		return &Index{tbi: tbi, ids: tbi.IDs(), maxPos: tabixMaxPos}, nil
+	case bytes.HasPrefix(bs, []byte("CSI")):
+		c, err := csi.ReadFrom(bytes.NewReader(bs))
+		if err != nil {
+			return nil, err
+		}
+		if len(bs) < 12 {
+			return nil, errors.New("truncated CSI index")
+		}
+		minShift := binary.LittleEndian.Uint32(bs[4:])
+		depth := binary.LittleEndian.Uint32(bs[8:])
+		idx := &Index{csi: c, ids: make(map[string]int), maxPos: 1<<(minShift+3*depth) - 1}
+		if idx.maxPos > maxPos || idx.maxPos <= 0 {
+			idx.maxPos = maxPos
+		}
+		names, err := csiNames(c.Auxilliary)
+		if err != nil {
+			return nil, err
+		}
+		for i, n := range names {
+			idx.ids[n] = i
+		}
+		return idx, nil
+	}
+	return nil, errors.New("not a tabix or CSI index")
+}
+
+// csiNames returns the sequence names stored in the auxiliary data of a CSI
+// index. The auxiliary data has the same layout as the tabix header: six
+// int32 configuration values followed by the length of the NUL terminated
+// names. Indexes of BCF files have no auxiliary data, the names are taken
+// from the BCF header instead (see setNames).
+func csiNames(aux []byte) ([]string, error) {
+	if len(aux) == 0 {
+		return nil, nil
+	}
+	if len(aux) < 28 {
+		return nil, errors.New("CSI index has malformed auxiliary data")
+	}
+	n := int(binary.LittleEndian.Uint32(aux[24:]))
+	if len(aux) < 28+n || n == 0 {
+		return nil, errors.New("CSI index has malformed sequence names")
+	}
+	return strings.Split(strings.TrimSuffix(string(aux[28:28+n]), "\x00"), "\x00"), nil
+}
+
+// setNames sets the sequence names of an index that does not store them
+// itself, where names[i] is the name of the i'th sequence in the index.
+func (idx *Index) setNames(names []string) {
+	if len(idx.ids) > 0 {
+		return
+	}
+	for i, n := range names {
+		idx.ids[n] = i
+	}
+}
+
+// Chunks returns the BGZF chunks, ordered by their offset in the file, that
+// may contain records overlapping the region. Records in the chunks must
+// still be tested for overlap with the region. A nil slice is returned if
+// the index has no records for the region.
+func (idx *Index) Chunks(r Region) ([]bgzf.Chunk, error) {
+	id, ok := idx.ids[r.Chrom]
+	if !ok {
+		return nil, nil
+	}
+	beg, end := r.Start-1, r.End
+	if beg < 0 {
+		beg = 0
+	}
+	if end > idx.maxPos {
+		end = idx.maxPos
+	}
+	if beg >= end {
+		return nil, nil
+	}
+	if idx.csi != nil {
+		return idx.csi.Chunks(id, beg, end), nil
+	}
+	chunks, err := idx.tbi.Chunks(r.Chrom, beg, end)
+	if err == index.ErrNoReference || err == index.ErrInvalid {
+		return nil, nil
+	}
+	return chunks, err
+}
+
+// fileOrder returns the regions that have records in the index, sorted into
+// the order they appear in the file with overlapping regions merged.
+func (idx *Index) fileOrder(regions []Region) []Region {
+	xs := []Region{}
+	for _, r := range regions {
+		if _, ok := idx.ids[r.Chrom]; ok {
+			xs = append(xs, r)
+		}
+	}
+	sort.SliceStable(xs, func(i, j int) bool {
+		if xs[i].Chrom != xs[j].Chrom {
+			return idx.ids[xs[i].Chrom] < idx.ids[xs[j].Chrom]
+		}
+		return xs[i].Start < xs[j].Start
+	})
+	merged := []Region{}
+	for _, r := range xs {
+		n := len(merged)
+		if n > 0 && merged[n-1].Chrom == r.Chrom && r.Start <= merged[n-1].End {
+			if r.End > merged[n-1].End {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// IndexFormat is the format of an index written by a Writer.
+type IndexFormat int
+
+const (
+	// NoIndex does not write an index.
+	NoIndex IndexFormat = iota
+	// TBI writes a tabix index.
+	TBI
+	// CSI writes a CSI index.
+	CSI
+)
+
+// Extension returns the file extension of the index format.
+func (f IndexFormat) Extension() string {
+	switch f {
+	case TBI:
+		return ".tbi"
+	case CSI:
+		return ".csi"
+	}
+	return ""
+}
+
+const (
+	indexMinShift = 14
+	indexDepth    = 5
+)
+
+// indexBuilder builds a tabix or CSI index as records are written. The
+// biogo/hts index types can not be used for this: tabix.Index.Add does not
+// remember reference names and csi.Index.Add calculates the wrong bin for
+// records spanning more than one 16kb window.
+type indexBuilder struct {
+	format  IndexFormat
+	names   []string
+	ids     map[string]int
+	fixed   bool
+	refs    []indexRef
+	last    int
+	lastPos int
+}
+
+type indexRef struct {
+	bins   map[uint32]*indexBin
+	linear []uint64
+}
+
+type indexBin struct {
+	loff   uint64
+	chunks [][2]uint64
+}
+
+func newIndexBuilder(format IndexFormat) *indexBuilder {
+	return &indexBuilder{format: format, ids: make(map[string]int), last: -1}
+}
+
+// setNames fixes the sequence names of the index, where names[i] is the
+// name of the i'th sequence. This is used for BCF, where the sequences are
+// those of the header and the names are not stored in the index.
+func (b *indexBuilder) setNames(names []string) {
+	b.names = names
+	b.fixed = true
+	for i, n := range names {
+		b.ids[n] = i
+	}
+}
+
+// check returns an error if a record on chrom covering the 0-based,
+// half-open interval [beg, end) can not be added to the index, because it
+// is out of order, on an unknown contig or too long to index.
+func (b *indexBuilder) check(chrom string, beg, end int) error {
+	rid, ok := b.ids[chrom]
+	if !ok && b.fixed {
+		return fmt.Errorf("contig %s is not defined in the header", chrom)
+	}
+	if ok && rid != b.last {
+		if rid < len(b.refs) && len(b.refs[rid].bins) > 0 {
+			return fmt.Errorf("records are not sorted: %s:%d follows records on %s", chrom, beg+1, b.names[b.last])
+		}
+	} else if ok && beg < b.lastPos {
+		return fmt.Errorf("records are not sorted: %s:%d follows %s:%d", chrom, beg+1, chrom, b.lastPos+1)
+	}
+	if end <= beg {
+		end = beg + 1
+	}
+	maxEnd := 1 << (indexMinShift + 3*indexDepth)
+	if b.format == TBI {
+		maxEnd = tabixMaxPos
+	}
+	if end > maxEnd {
+		return fmt.Errorf("position %d can not be indexed", end)
+	}
+	return nil
+}
+
+// add records that the record on chrom covering the 0-based, half-open
+// interval [beg, end) is stored between the virtual offsets begin and stop.
+// Records must be added in sorted order: all records on a contig must be
+// contiguous and sorted by position (see check).
+func (b *indexBuilder) add(chrom string, beg, end int, begin, stop uint64) error {
+	if err := b.check(chrom, beg, end); err != nil {
+		return err
+	}
+	rid, ok := b.ids[chrom]
+	if !ok {
+		rid = len(b.names)
+		b.ids[chrom] = rid
+		b.names = append(b.names, chrom)
+	}
+	if rid != b.last {
+		b.last = rid
+		b.lastPos = 0
+	}
+	if end <= beg {
+		end = beg + 1
+	}
+	for rid >= len(b.refs) {
+		b.refs = append(b.refs, indexRef{bins: make(map[uint32]*indexBin)})
+	}
+	b.lastPos = beg
+	ref := &b.refs[rid]
+	bin := reg2bin(beg, end)
+	ib, ok := ref.bins[bin]
+	if !ok {
+		ib = &indexBin{loff: begin}
+		ref.bins[bin] = ib
+	}
+	if n := len(ib.chunks); n > 0 && ib.chunks[n-1][1] >= begin {
+		ib.chunks[n-1][1] = stop
+	} else {
+		ib.chunks = append(ib.chunks, [2]uint64{begin, stop})
+	}
+	for w := beg >> indexMinShift; w <= (end-1)>>indexMinShift; w++ {
+		for len(ref.linear) <= w {
+			ref.linear = append(ref.linear, 0)
+		}
+		if ref.linear[w] == 0 {
+			ref.linear[w] = begin
+		}
+	}
+	return nil
+}
+
+// reg2bin returns the bin of the 0-based, half-open interval [beg, end) as
+// calculated in the SAM specification.
+func reg2bin(beg, end int) uint32 {
+	end--
+	s := uint(indexMinShift)
+	t := ((1 << (3 * indexDepth)) - 1) / 7
+	for l := indexDepth; l > 0; l-- {
+		if beg>>s == end>>s {
+			return uint32(t + beg>>s)
+		}
+		s += 3
+		t -= 1 << (3 * (l - 1))
+	}
+	return 0
+}
+
+// write writes the index, BGZF compressed, to w.
+func (b *indexBuilder) write(w io.Writer) error {
+	bg := bgzf.NewWriter(w, 1)
+	le := binary.LittleEndian
+	var buf bytes.Buffer
+	aux := tabixHeader(b.names)
+	if b.fixed {
+		// A BCF index: the names are in the BCF header.
+		aux = nil
+		for len(b.refs) < len(b.names) {
+			b.refs = append(b.refs, indexRef{bins: make(map[uint32]*indexBin)})
+		}
+	}
+	if b.format == TBI {
+		buf.WriteString("TBI\x01")
+		binary.Write(&buf, le, int32(len(b.refs)))
+		buf.Write(aux)
+	} else {
+		buf.WriteString("CSI\x01")
+		binary.Write(&buf, le, int32(indexMinShift))
+		binary.Write(&buf, le, int32(indexDepth))
+		binary.Write(&buf, le, int32(len(aux)))
+		buf.Write(aux)
+		binary.Write(&buf, le, int32(len(b.refs)))
+	}
+	for _, ref := range b.refs {
+		bins := make([]uint32, 0, len(ref.bins))
+		for bin := range ref.bins {
+			bins = append(bins, bin)
+		}
+		sort.Slice(bins, func(i, j int) bool { return bins[i] < bins[j] })
+		binary.Write(&buf, le, int32(len(bins)))
+		for _, bin := range bins {
+			ib := ref.bins[bin]
+			binary.Write(&buf, le, bin)
+			if b.format == CSI {
+				binary.Write(&buf, le, ib.loff)
+			}
+			binary.Write(&buf, le, int32(len(ib.chunks)))
+			for _, c := range ib.chunks {
+				binary.Write(&buf, le, c)
+			}
+		}
+		if b.format == TBI {
+			// Windows without records take the offset of the
+			// preceding window.
+			for i := 1; i < len(ref.linear); i++ {
+				if ref.linear[i] == 0 {
+					ref.linear[i] = ref.linear[i-1]
+				}
+			}
+			binary.Write(&buf, le, int32(len(ref.linear)))
+			binary.Write(&buf, le, ref.linear)
+		}
+	}
+	if _, err := bg.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return bg.Close()
+}
+
+// tabixHeader returns the tabix configuration for VCF and the sequence
+// names. This is the tabix index header and the auxiliary data of a CSI
+// index of a VCF.
+func tabixHeader(names []string) []byte {
+	var buf bytes.Buffer
+	nm := strings.Join(names, "\x00") + "\x00"
+	// Format VCF, sequence names in column 1, positions in column 2, no
+	// end column, '#' comments and no skipped lines.
+	for _, x := range []int32{2, 1, 2, 0, '#', 0, int32(len(nm))} {
+		binary.Write(&buf, binary.LittleEndian, x)
+	}
+	buf.WriteString(nm)
+	return buf.Bytes()
+}
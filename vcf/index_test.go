@@ -0,0 +1,144 @@
+package vcf
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeIndexedTestFile writes the VCF content as BGZF to path with an index
+// of the given format.
+func writeIndexedTestFile(t *testing.T, path, content string, format IndexFormat) {
+	t.Helper()
+	r, err := NewReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWriter(path, WithIndex(format))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(r.Header()); err != nil {
+		t.Fatal(err)
+	}
+	for r.Scan() {
+		if err := w.WriteVariant(r.Variant()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const indexTestVCF = `##fileformat=VCFv4.2
+##INFO=<ID=END,Number=1,Type=Integer,Description="End position">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##contig=<ID=1,length=249250621>
+##contig=<ID=2,length=243199373>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	.	A	C	.	PASS	.	GT	0/1	0/0
+1	20000	.	A	<DEL>	.	PASS	END=90000	GT	0/1	0/0
+1	50000	.	ATG	A	.	PASS	.	GT	0/1	0/0
+1	100000	.	A	C	.	PASS	.	GT	0/1	0/0
+2	100	.	G	T	.	PASS	.	GT	0/1	0/0
+2	5000000	.	G	T	.	PASS	.	GT	0/1	0/0
+`
+
+func TestOpenIndex(t *testing.T) {
+	path := t.TempDir() + "/test.vcf.gz"
+	if _, err := OpenIndex(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenIndex() error = %v, want not exist", err)
+	}
+	writeIndexedTestFile(t, path, indexTestVCF, CSI)
+	idx, err := OpenIndex(path)
+	if err != nil {
+		t.Fatalf("OpenIndex() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		region Region
+		want   bool
+	}{
+		{"t1", Region{"1", 1, maxPos}, true},
+		{"t2", Region{"1", 100, 100}, true},
+		{"t3", Region{"2", 4000000, 6000000}, true},
+		{"t4", Region{"3", 1, maxPos}, false},
+		{"t5", Region{"2", 6000000, 7000000}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := idx.Chunks(tt.region)
+			if err != nil {
+				t.Fatalf("Index.Chunks() error = %v", err)
+			}
+			if got := len(chunks) > 0; got != tt.want {
+				t.Errorf("Index.Chunks() = %v, want chunks %v", chunks, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewScanner_indexed(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		format IndexFormat
+	}{
+		{"tbi", "test.vcf.gz", TBI},
+		{"csi", "test.vcf.gz", CSI},
+		{"bcf", "test.bcf", CSI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testIndexedScanner(t, tt.file, tt.format)
+		})
+	}
+}
+
+func testIndexedScanner(t *testing.T, file string, format IndexFormat) {
+	path := t.TempDir() + "/" + file
+	writeIndexedTestFile(t, path, indexTestVCF, format)
+	v, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		loc  []string
+		want []string
+	}{
+		{"contig", []string{"2"}, []string{"2:100", "2:5000000"}},
+		{"position", []string{"1:100000"}, []string{"1:100000"}},
+		{"within END", []string{"1:60000-70000"}, []string{"1:20000"}},
+		{"deleted base", []string{"1:50002"}, []string{"1:20000", "1:50000"}},
+		{"file order", []string{"2:100", "1:100"}, []string{"1:100", "2:100"}},
+		{"spanning regions", []string{"1:30000", "1:60000"}, []string{"1:20000"}},
+		{"overlapping regions", []string{"1:1-150", "1:100-20000"}, []string{"1:100", "1:20000"}},
+		{"empty", []string{"2:200-300"}, []string{}},
+		{"unknown contig", []string{"X"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScanner(v, tt.loc...)
+			if err != nil {
+				t.Fatalf("NewScanner() error = %v", err)
+			}
+			if s.idx == nil {
+				t.Fatal("index was not used")
+			}
+			got := []string{}
+			for _, v := range scanAll(t, s) {
+				got = append(got, Region{v.Chrom, v.Pos, v.Pos}.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("variants = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
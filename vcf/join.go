@@ -0,0 +1,339 @@
+package vcf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VariantScanner is the interface of Scanner and of the types that read
+// variants from one, for example, Joiner.
+type VariantScanner interface {
+	Scan() bool
+	Variant() Variant
+	Err() error
+}
+
+// Join combines variants with the same Chrom, Pos and Ref into a single
+// multi-allelic variant, in the same way as `bcftools norm -m+`. It is the
+// inverse of Split. The alternate alleles of the variants are combined in
+// the order they appear, INFO and FORMAT fields declared in the header with
+// Number=A, R or G are combined allele by allele, with "." for values that
+// are not given by any variant, and other fields are taken from the first
+// variant that has them. The FILTER of the combined variant lists every
+// filter of the variants, and is PASS only if every variant passed.
+// Genotype allele indexes are re-encoded for the combined alleles; at each
+// position of a genotype the first alternate allele called by any of the
+// variants is used. All variants must have the same samples.
+func Join(vs ...Variant) (Variant, error) {
+	if len(vs) == 0 {
+		return Variant{}, errors.New("no variants to join")
+	}
+	first := vs[0]
+	if len(vs) == 1 {
+		return first, nil
+	}
+	x := first
+	x.Alt = []string{}
+	// maps[i][j] is the index in x of allele j of vs[i].
+	maps := make([][]int, len(vs))
+	ids := []string{}
+	x.Filter = []string{}
+	passed := true
+	for i, v := range vs {
+		if v.Chrom != first.Chrom || v.Pos != first.Pos || v.Ref != first.Ref {
+			return Variant{}, fmt.Errorf("can not join %s:%d:%s with %s:%d:%s", v.Chrom, v.Pos, v.Ref, first.Chrom, first.Pos, first.Ref)
+		}
+		if len(v.genotypes) != len(first.genotypes) {
+			return Variant{}, fmt.Errorf("can not join %s:%d: variants have different samples", v.Chrom, v.Pos)
+		}
+		for j, g := range v.genotypes {
+			if g.Name != first.genotypes[j].Name {
+				return Variant{}, fmt.Errorf("can not join %s:%d: variants have different samples", v.Chrom, v.Pos)
+			}
+		}
+		maps[i] = []int{0}
+		for j := 0; j < v.nAlt(); j++ {
+			maps[i] = append(maps[i], addAllele(&x.Alt, v.Alt[j])+1)
+		}
+		if v.ID != "." && v.ID != "" && !stringSliceContains(ids, v.ID) {
+			ids = append(ids, v.ID)
+		}
+		passed = passed && stringSliceContains(v.Filter, "PASS")
+		for _, f := range v.Filter {
+			if f != "PASS" && f != "." && !stringSliceContains(x.Filter, f) {
+				x.Filter = append(x.Filter, f)
+			}
+		}
+		x.Qual = maxQual(x.Qual, v.Qual)
+	}
+	if len(x.Alt) == 0 {
+		x.Alt = []string{"."}
+	}
+	// PASS can not be combined with other filters. The text and BCF readers
+	// do not keep PASS, so variants read from a file never add it here.
+	if len(x.Filter) == 0 && passed {
+		x.Filter = []string{"PASS"}
+	}
+	x.ID = "."
+	if len(ids) > 0 {
+		x.ID = strings.Join(ids, ";")
+	}
+
+	x.Info = make(map[string]string)
+	for _, v := range vs {
+		for k := range v.Info {
+			if _, ok := x.Info[k]; ok {
+				continue
+			}
+			values := make([]string, len(vs))
+			for i, w := range vs {
+				values[i] = w.Info[k]
+			}
+			value, err := joinValues(first.definition("INFO", k), values, vs, maps, len(x.Alt), 2)
+			if err != nil {
+				return Variant{}, x.infoError(k, err)
+			}
+			x.Info[k] = value
+		}
+	}
+
+	x.Format = []string{}
+	for _, v := range vs {
+		for _, f := range v.Format {
+			if !stringSliceContains(x.Format, f) {
+				x.Format = append(x.Format, f)
+			}
+		}
+	}
+	x.genotypes = nil
+	for j, g := range first.Genotypes() {
+		values := make(map[string]string, len(x.Format))
+		for _, k := range x.Format {
+			samples := make([]string, len(vs))
+			for i, v := range vs {
+				samples[i] = v.genotypes[j].values[k]
+			}
+			if k == "GT" {
+				values[k] = joinGenotypes(samples, maps)
+				continue
+			}
+			value, err := joinValues(first.definition("FORMAT", k), samples, vs, maps, len(x.Alt), g.gtPloidy())
+			if err != nil {
+				return Variant{}, g.formatError(k, err)
+			}
+			values[k] = value
+		}
+		ng, err := NewGenotype(g.Name, values)
+		if err != nil {
+			return Variant{}, g.formatError("GT", err)
+		}
+		if err := x.AddGenotype(ng); err != nil {
+			return Variant{}, err
+		}
+	}
+	return x, nil
+}
+
+// addAllele returns the index of allele in alleles, appending it if it is
+// not already present.
+func addAllele(alleles *[]string, allele string) int {
+	for i, a := range *alleles {
+		if a == allele {
+			return i
+		}
+	}
+	*alleles = append(*alleles, allele)
+	return len(*alleles) - 1
+}
+
+// maxQual returns the larger of two QUAL values, ignoring missing values.
+func maxQual(a, b string) string {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	switch {
+	case errB != nil:
+		if errA != nil {
+			return "."
+		}
+		return a
+	case errA != nil || y > x:
+		return b
+	}
+	return a
+}
+
+// joinValues combines the values of a field from each of vs (see Join).
+// values[i] is the value in vs[i], or "" if it does not have the field.
+func joinValues(def *HeaderLine, values []string, vs []Variant, maps [][]int, nAlt, ploidy int) (string, error) {
+	number := ""
+	if def != nil {
+		number = def.Get("Number")
+	}
+	if number != "A" && number != "R" && number != "G" {
+		for _, value := range values {
+			if value != "" {
+				return value, nil
+			}
+		}
+		return ".", nil
+	}
+	var ys []string
+	if number == "G" {
+		ys = make([]string, numGenotypes(nAlt+1, ploidy))
+	} else {
+		ys = make([]string, nAlt+1)
+	}
+	for i := range ys {
+		ys[i] = "."
+	}
+	genotypes := genotypeOrder(nAlt+1, ploidy)
+	for i, value := range values {
+		if value == "" || value == "." {
+			continue
+		}
+		xs, err := splitValues(def, value, vs[i].nAlt(), ploidy)
+		if err != nil {
+			return "", err
+		}
+		switch number {
+		case "A":
+			for j, x := range xs {
+				if ys[maps[i][j+1]] == "." {
+					ys[maps[i][j+1]] = x
+				}
+			}
+		case "R":
+			for j, x := range xs {
+				if ys[maps[i][j]] == "." {
+					ys[maps[i][j]] = x
+				}
+			}
+		case "G":
+			local := make(map[int]int, len(maps[i]))
+			for j, m := range maps[i] {
+				local[m] = j
+			}
+		genotype:
+			for k, gt := range genotypes {
+				old := make([]int, len(gt))
+				for n, a := range gt {
+					j, ok := local[a]
+					if !ok {
+						continue genotype
+					}
+					old[n] = j
+				}
+				if ys[k] == "." {
+					ys[k] = xs[genotypeIndex(old)]
+				}
+			}
+		}
+	}
+	if number == "A" {
+		ys = ys[1:]
+	}
+	return strings.Join(ys, ","), nil
+}
+
+// joinGenotypes combines the GT values of a sample from several variants,
+// where maps[i] maps the alleles of the i'th variant to the joined alleles.
+// The separators are taken from the first genotype.
+func joinGenotypes(gts []string, maps [][]int) string {
+	var alleles []string
+	var seps []byte
+	for i, gt := range gts {
+		start, n := 0, 0
+		for j := 0; j <= len(gt); j++ {
+			if j < len(gt) && gt[j] != '/' && gt[j] != '|' {
+				continue
+			}
+			allele := gt[start:j]
+			if n == len(alleles) {
+				alleles = append(alleles, ".")
+				if j < len(gt) {
+					seps = append(seps, gt[j])
+				}
+			}
+			if a, err := strconv.Atoi(allele); err == nil && a < len(maps[i]) {
+				if alleles[n] == "." || alleles[n] == "0" {
+					alleles[n] = strconv.Itoa(maps[i][a])
+				}
+			}
+			start = j + 1
+			n++
+		}
+	}
+	var b strings.Builder
+	for i, a := range alleles {
+		if i > 0 {
+			sep := byte('/')
+			if i-1 < len(seps) {
+				sep = seps[i-1]
+			}
+			b.WriteByte(sep)
+		}
+		b.WriteString(a)
+	}
+	if b.Len() == 0 {
+		return "."
+	}
+	return b.String()
+}
+
+// Joiner reads variants from a VariantScanner, joining adjacent variants
+// with the same Chrom, Pos and Ref into multi-allelic variants (see Join).
+type Joiner struct {
+	s     VariantScanner
+	next  *Variant
+	token Variant
+	err   error
+}
+
+// NewJoiner creates a Joiner that reads variants from s.
+func NewJoiner(s VariantScanner) *Joiner {
+	return &Joiner{s: s}
+}
+
+// Scan advances to the next joined variant, which is then available from
+// the Variant method. It returns false when there are no more variants or
+// an error occurs.
+func (j *Joiner) Scan() bool {
+	if j.err != nil {
+		return false
+	}
+	if j.next == nil {
+		if !j.s.Scan() {
+			return false
+		}
+		v := j.s.Variant()
+		j.next = &v
+	}
+	group := []Variant{*j.next}
+	j.next = nil
+	for j.s.Scan() {
+		v := j.s.Variant()
+		if v.Chrom != group[0].Chrom || v.Pos != group[0].Pos || v.Ref != group[0].Ref {
+			j.next = &v
+			break
+		}
+		group = append(group, v)
+	}
+	j.token, j.err = Join(group...)
+	return j.err == nil
+}
+
+// Variant returns the most recent variant read by Scan.
+func (j *Joiner) Variant() Variant {
+	return j.token
+}
+
+// Err returns the first error encountered by the Joiner or its
+// VariantScanner.
+func (j *Joiner) Err() error {
+	if j.err != nil {
+		return j.err
+	}
+	return j.s.Err()
+}
@@ -0,0 +1,154 @@
+package vcf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const joinTestVCF = `##fileformat=VCFv4.2
+##FILTER=<ID=q10,Description="Quality below 10">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	rs1	A	C	20	PASS	DP=20;AC=1	GT:AD	0/1:10,10	0/0:5,0
+1	100	rs2	A	G	30	q10	DP=21;AC=2	GT:AD	0/0:10,0	1/1:0,5
+1	100	.	AT	A	.	PASS	AC=1	GT:AD	0/1:5,5	0/0:5,0
+1	200	.	A	T	.	PASS	AC=1	GT:AD	0/1:5,5	./.:.
+`
+
+func TestJoin(t *testing.T) {
+	s, err := NewReader(strings.NewReader(splitTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := scanAll(t, s)
+	xs, err := vs[0].Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := Join(xs...)
+	if err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	if x.Chrom != "1" || x.Pos != 100 || x.ID != "rs1" || x.Ref != "A" || x.Qual != "50" {
+		t.Errorf("fixed fields changed: %+v", x)
+	}
+	if want := []string{"C", "G"}; !reflect.DeepEqual(x.Alt, want) {
+		t.Errorf("Alt = %v, want %v", x.Alt, want)
+	}
+	// GL for 1/2 is not given by either of the biallelic variants.
+	wantInfo := map[string]string{"DP": "20", "AC": "1,2", "RAF": "0.25,0.25,0.5", "GL": "0,1,2,3,.,5"}
+	if !reflect.DeepEqual(x.Info, wantInfo) {
+		t.Errorf("Info = %v, want %v", x.Info, wantInfo)
+	}
+	type sample struct {
+		GT, AD, PL string
+	}
+	want := []sample{{"1/2", "0,10,10", "60,50,40,30,.,10"}, {"0|2", "5,.,5", "0,1,2,3,.,5"}, {"2", "1,0,9", "7,8,9"}}
+	got := []sample{}
+	for _, g := range x.Genotypes() {
+		got = append(got, sample{g.values["GT"], g.values["AD"], g.values["PL"]})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("samples = %v, want %v", got, want)
+	}
+	g, _ := x.Sample("S1")
+	if alleles, err := g.Alleles(); err != nil || !reflect.DeepEqual(alleles, []string{"C", "G"}) {
+		t.Errorf("Genotype.Alleles() = %v, %v, want [C G]", alleles, err)
+	}
+}
+
+func TestJoin_errors(t *testing.T) {
+	s, err := NewReader(strings.NewReader(joinTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := scanAll(t, s)
+	renamed := vs[1]
+	renamed.genotypes = append([]Genotype{}, vs[1].genotypes...)
+	renamed.genotypes[1].Name = "S3"
+	tests := []struct {
+		name string
+		vs   []Variant
+		want string
+	}{
+		{"none", nil, "no variants"},
+		{"ref", []Variant{vs[0], vs[2]}, "can not join"},
+		{"pos", []Variant{vs[0], vs[3]}, "can not join"},
+		{"samples", []Variant{vs[0], renamed}, "different samples"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Join(tt.vs...); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Join() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin_filter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters [][]string
+		want    []string
+	}{
+		{"all passed", [][]string{{"PASS"}, {"PASS"}}, []string{"PASS"}},
+		{"one filtered", [][]string{{"PASS"}, {"q10"}}, []string{"q10"}},
+		{"filtered", [][]string{{"q10", "s50"}, {"PASS"}, {"s50", "LowQual"}}, []string{"q10", "s50", "LowQual"}},
+		{"not filtered", [][]string{{"PASS"}, {}}, []string{}},
+		{"missing", [][]string{{"."}, {"."}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := []Variant{}
+			for i, f := range tt.filters {
+				vs = append(vs, Variant{Chrom: "1", Pos: 100, Ref: "A", Alt: []string{string("CGT"[i])}, Filter: f})
+			}
+			x, err := Join(vs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(x.Filter, tt.want) {
+				t.Errorf("Join() Filter = %v, want %v", x.Filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoiner(t *testing.T) {
+	s, err := NewReader(strings.NewReader(joinTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := NewJoiner(s)
+	type variant struct {
+		Pos           int
+		ID, Ref, Qual string
+		Alt, Filter   []string
+		AC, DP        string
+		GT            []string
+	}
+	got := []variant{}
+	for j.Scan() {
+		v := j.Variant()
+		gts := []string{}
+		for _, g := range v.Genotypes() {
+			gts = append(gts, g.values["GT"])
+		}
+		got = append(got, variant{v.Pos, v.ID, v.Ref, v.Qual, v.Alt, v.Filter, v.Info["AC"], v.Info["DP"], gts})
+	}
+	if err := j.Err(); err != nil {
+		t.Fatalf("Joiner.Err() = %v", err)
+	}
+	want := []variant{
+		{100, "rs1;rs2", "A", "30", []string{"C", "G"}, []string{"q10"}, "1,2", "20", []string{"0/1", "2/2"}},
+		{100, ".", "AT", ".", []string{"A"}, []string{}, "1", "", []string{"0/1", "0/0"}},
+		{200, ".", "A", ".", []string{"T"}, []string{}, "1", "", []string{"0/1", "./."}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Joiner variants = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,202 @@
+package vcf
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Leniency controls how a Scanner handles malformed records.
+type Leniency int
+
+const (
+	// LeniencyStrict stops scanning with an error at the first malformed
+	// record. It is the default.
+	LeniencyStrict Leniency = iota
+	// LeniencyLenient repairs malformed records where it can, dropping
+	// extra sample columns and subfields and giving missing samples
+	// missing values, and skips records it can not parse. Each repair is
+	// counted and logged (see Scanner.Repairs and WithRepairLog).
+	LeniencyLenient
+)
+
+// A FixUp repairs a known quirk in the output of a particular tool. Fix is
+// called with the columns of each text record before it is parsed and
+// returns true if it changed them. Fix-ups are not applied to BCF records.
+type FixUp struct {
+	Name string
+	Fix  func(cols []string) bool
+}
+
+// TSO500Genotypes rewrites the "1/." genotypes written by the TSO500 Local
+// App to "1/1". They appear when all reads in the AD count support the ALT
+// allele but the DP is higher, presumably because some reads are filtered.
+var TSO500Genotypes = FixUp{
+	Name: "TSO500 1/. genotype",
+	Fix: func(cols []string) bool {
+		return rewriteGenotypes(cols, map[string]string{"1/.": "1/1"})
+	},
+}
+
+// rewriteGenotypes replaces the GT values of the samples in cols that are
+// keys of m with their values, returning true if any are replaced.
+func rewriteGenotypes(cols []string, m map[string]string) bool {
+	if len(cols) < 10 || !strings.HasPrefix(cols[8], "GT") {
+		return false
+	}
+	changed := false
+	for i, col := range cols[9:] {
+		gt := col
+		rest := ""
+		if j := strings.IndexByte(col, ':'); j >= 0 {
+			gt, rest = col[:j], col[j:]
+		}
+		if x, ok := m[gt]; ok {
+			cols[9+i] = x + rest
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Repair is a change made to a record by a Scanner, either by a FixUp or a
+// lenient repair.
+type Repair struct {
+	Chrom string
+	Pos   int
+	// Reason is the name of the FixUp or a description of the repair.
+	Reason string
+}
+
+func (r Repair) String() string {
+	return fmt.Sprintf("%s:%d: %s", r.Chrom, r.Pos, r.Reason)
+}
+
+// ReaderOption configures how a Scanner parses records (see
+// Scanner.SetOptions).
+type ReaderOption func(*readerConfig)
+
+type readerConfig struct {
+	leniency Leniency
+	fixUps   []FixUp
+	log      *log.Logger
+	// repairs counts the repairs made, by reason.
+	repairs map[string]int
+}
+
+// Strict makes a Scanner stop at the first malformed record.
+func Strict() ReaderOption {
+	return func(c *readerConfig) {
+		c.leniency = LeniencyStrict
+	}
+}
+
+// Lenient makes a Scanner repair or skip malformed records (see
+// LeniencyLenient).
+func Lenient() ReaderOption {
+	return func(c *readerConfig) {
+		c.leniency = LeniencyLenient
+	}
+}
+
+// WithFixUp applies f to every text record, in the order the fix-ups are
+// given, in either mode.
+func WithFixUp(f FixUp) ReaderOption {
+	return func(c *readerConfig) {
+		c.fixUps = append(c.fixUps, f)
+	}
+}
+
+// WithRepairLog logs every repair made by a Scanner to l.
+func WithRepairLog(l *log.Logger) ReaderOption {
+	return func(c *readerConfig) {
+		c.log = l
+	}
+}
+
+// repair records a repair to the record with the columns cols.
+func (c *readerConfig) repair(cols []string, reason string) {
+	r := Repair{Chrom: cols[0], Reason: reason}
+	if len(cols) > 1 {
+		r.Pos, _ = strconv.Atoi(cols[1])
+	}
+	if c.repairs == nil {
+		c.repairs = make(map[string]int)
+	}
+	c.repairs[reason]++
+	if c.log != nil {
+		c.log.Print(r)
+	}
+}
+
+// parse applies the fix-ups to the columns of a text record and parses it.
+// In lenient mode malformed columns are repaired first, and skip is true if
+// the record can still not be parsed.
+func (c *readerConfig) parse(cols []string, samples []string) (v Variant, skip bool, err error) {
+	for _, f := range c.fixUps {
+		if f.Fix(cols) {
+			c.repair(cols, f.Name)
+		}
+	}
+	if c.leniency == LeniencyLenient {
+		cols = c.repairColumns(cols, samples)
+	}
+	v, err = parseVcfColumns(cols, samples)
+	if err != nil && c.leniency == LeniencyLenient {
+		c.repair(cols, "skipped unparsable record")
+		return Variant{}, true, nil
+	}
+	return v, false, err
+}
+
+// repairColumns drops extra sample columns and subfields, and adds missing
+// sample columns, so cols match samples and the FORMAT column.
+func (c *readerConfig) repairColumns(cols []string, samples []string) []string {
+	if len(cols) < 9 {
+		return cols
+	}
+	n := 9 + len(samples)
+	if len(cols) > n {
+		cols = cols[:n]
+		c.repair(cols, "dropped extra sample columns")
+	}
+	if len(cols) < n {
+		for len(cols) < n {
+			cols = append(cols, ".")
+		}
+		c.repair(cols, "added missing sample columns")
+	}
+	nFormat := strings.Count(cols[8], ":") + 1
+	for i := 9; i < len(cols); i++ {
+		if strings.Count(cols[i], ":") < nFormat {
+			continue
+		}
+		xs := strings.SplitN(cols[i], ":", nFormat+1)
+		cols[i] = strings.Join(xs[:nFormat], ":")
+		c.repair(cols, "dropped extra sample subfields")
+	}
+	return cols
+}
+
+// SetOptions configures how the Scanner parses records. It must be called
+// before Scan.
+func (s *Scanner) SetOptions(opts ...ReaderOption) error {
+	if s.scanCalled {
+		return fmt.Errorf("options set after Scan")
+	}
+	for _, opt := range opts {
+		opt(&s.config)
+	}
+	return nil
+}
+
+// Repairs returns the number of repairs made so far by the Scanner's
+// fix-ups and lenient mode, by reason.
+func (s *Scanner) Repairs() map[string]int {
+	xs := make(map[string]int, len(s.config.repairs))
+	for k, n := range s.config.repairs {
+		xs[k] = n
+	}
+	return xs
+}
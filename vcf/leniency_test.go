@@ -0,0 +1,134 @@
+package vcf
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const leniencyTestVCF = `##fileformat=VCFv4.2
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##contig=<ID=1,length=249250621>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	.	A	C	.	PASS	.	GT:AD	1/.:0,10	0/1
+1	200	.	A	C	.	PASS	.	GT:AD	0/1:5,5	0/1:5,5	0/0:10,0
+1	300	.	A	C	.	PASS	.	GT	0/1:5,5	0/1
+1	400	.	A	C	.	PASS	.	GT:AD	0/1:5,5
+1	x	.	A	C	.	PASS	.	GT:AD	0/1:5,5	0/1:5,5
+1	500	.	A	C	.	PASS	.	GT:AD	0/1:5,5	0/1:5,5
+`
+
+func TestScanner_SetOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ReaderOption
+		want    []string
+		repairs map[string]int
+		wantErr bool
+	}{
+		{
+			name:    "strict",
+			want:    []string{"1:100 1/.:0,10 0/1:."},
+			repairs: map[string]int{},
+			wantErr: true,
+		},
+		{
+			name: "lenient",
+			opts: []ReaderOption{Lenient()},
+			want: []string{
+				"1:100 1/.:0,10 0/1:.",
+				"1:200 0/1:5,5 0/1:5,5",
+				"1:300 0/1 0/1",
+				"1:400 0/1:5,5 .:.",
+				"1:500 0/1:5,5 0/1:5,5",
+			},
+			repairs: map[string]int{
+				"dropped extra sample columns":   1,
+				"dropped extra sample subfields": 1,
+				"added missing sample columns":   1,
+				"skipped unparsable record":      1,
+			},
+		},
+		{
+			name: "fix-up",
+			opts: []ReaderOption{Lenient(), WithFixUp(TSO500Genotypes)},
+			want: []string{
+				"1:100 1/1:0,10 0/1:.",
+				"1:200 0/1:5,5 0/1:5,5",
+				"1:300 0/1 0/1",
+				"1:400 0/1:5,5 .:.",
+				"1:500 0/1:5,5 0/1:5,5",
+			},
+			repairs: map[string]int{
+				"TSO500 1/. genotype":            1,
+				"dropped extra sample columns":   1,
+				"dropped extra sample subfields": 1,
+				"added missing sample columns":   1,
+				"skipped unparsable record":      1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := New(writeTestFile(t, "test.vcf", leniencyTestVCF))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewScanner(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			var buf bytes.Buffer
+			opts := append(tt.opts, WithRepairLog(log.New(&buf, "", 0)))
+			if err := s.SetOptions(opts...); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for s.Scan() {
+				x := s.Variant()
+				fields := []string{fmt.Sprintf("%s:%d", x.Chrom, x.Pos)}
+				for _, g := range x.Genotypes() {
+					fields = append(fields, g.AsVCFString())
+				}
+				got = append(got, strings.Join(fields, " "))
+			}
+			if (s.Err() != nil) != tt.wantErr {
+				t.Errorf("Scanner.Err() = %v, wantErr %v", s.Err(), tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scanner variants = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(s.Repairs(), tt.repairs) {
+				t.Errorf("Scanner.Repairs() = %v, want %v", s.Repairs(), tt.repairs)
+			}
+			n := 0
+			for _, c := range tt.repairs {
+				n += c
+			}
+			if logged := strings.Count(buf.String(), "\n"); logged != n {
+				t.Errorf("logged %d repairs, want %d:\n%s", logged, n, buf.String())
+			}
+		})
+	}
+}
+
+func TestScanner_SetOptions_afterScan(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.vcf", testVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Scan()
+	if err := s.SetOptions(Lenient()); err == nil {
+		t.Error("Scanner.SetOptions() after Scan did not return an error")
+	}
+}
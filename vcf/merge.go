@@ -0,0 +1,439 @@
+package vcf
+
+import (
+	"container/heap"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Merger merges the variants of several coordinate sorted VCFs into a single
+// multi-sample VCF, in the same way as `bcftools merge -m none`. Records at
+// the same position with the same REF and ALT alleles are merged into one
+// variant with genotypes for the samples of every input. Samples of inputs
+// without such a record are given missing values, with a missing GT of the
+// ploidy of the other samples. The AC, AN and AF INFO fields are
+// recomputed from the merged genotypes; other INFO fields are taken from
+// the first input that has them.
+type Merger struct {
+	header   Header
+	scanners []*Scanner
+	// offsets[i] is the index in the merged samples of the first sample of
+	// input i.
+	offsets []int
+	order   contigOrder
+	heads   mergeHeap
+	pending []Variant
+	token   Variant
+	err     error
+}
+
+// NewMerger creates a Merger that reads the variants of vs, which must be
+// sorted by coordinate. Their headers are merged (see Merger.Header); an
+// error is returned if the inputs define an INFO or FORMAT field or a contig
+// differently, or have samples in common.
+func NewMerger(vs ...VCF) (*Merger, error) {
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("no VCFs to merge")
+	}
+	m := &Merger{}
+	var err error
+	m.header, m.offsets, err = mergeHeaders(vs)
+	if err != nil {
+		return nil, err
+	}
+	m.order = newContigOrder(m.header)
+	for _, v := range vs {
+		s, err := NewScanner(v)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.scanners = append(m.scanners, s)
+	}
+	m.heads = mergeHeap{order: m.order}
+	for i := range m.scanners {
+		if !m.advance(i) {
+			m.Close()
+			return nil, m.err
+		}
+	}
+	return m, nil
+}
+
+// mergeHeaders returns the union of the headers of vs and the offset of the
+// samples of each of vs in the merged samples.
+func mergeHeaders(vs []VCF) (Header, []int, error) {
+	h := Header{}
+	offsets := make([]int, len(vs))
+	for i, v := range vs {
+		offsets[i] = len(h.Samples)
+		if err := h.Merge(v.Header, DistinctSamples); err != nil {
+			return Header{}, nil, fmt.Errorf("unable to merge header of %s: %w", v.file, err)
+		}
+	}
+	return h, offsets, nil
+}
+
+// Header returns the merged header: the union of the header lines of the
+// inputs, with the samples of each input in turn.
+func (m *Merger) Header() Header {
+	return m.header
+}
+
+// advance reads the next variant of input i onto the heap, returning false
+// if it fails.
+func (m *Merger) advance(i int) bool {
+	s := m.scanners[i]
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			m.err = fmt.Errorf("unable to read %s: %w", s.vcf.file, err)
+			return false
+		}
+		return true
+	}
+	v := s.Variant()
+	if p := m.heads.last(i); p != nil && m.order.less(v, *p) {
+		m.err = fmt.Errorf("%s is not sorted: %s:%d is after %s:%d", s.vcf.file, v.Chrom, v.Pos, p.Chrom, p.Pos)
+		return false
+	}
+	heap.Push(&m.heads, mergeHead{v, i})
+	return true
+}
+
+// Scan advances to the next merged variant, which is then available from
+// the Variant method. It returns false when there are no more variants or
+// an error occurs.
+func (m *Merger) Scan() bool {
+	if m.err != nil {
+		return false
+	}
+	if len(m.pending) == 0 {
+		if len(m.heads.xs) == 0 {
+			return false
+		}
+		// Take every variant at the next position.
+		first := m.heads.xs[0].v
+		var group []mergeHead
+		for len(m.heads.xs) > 0 && !m.order.less(first, m.heads.xs[0].v) {
+			h := heap.Pop(&m.heads).(mergeHead)
+			group = append(group, h)
+			if !m.advance(h.input) {
+				return false
+			}
+		}
+		var err error
+		if m.pending, err = m.mergeGroup(group); err != nil {
+			m.err = err
+			return false
+		}
+	}
+	m.token = m.pending[0]
+	m.pending = m.pending[1:]
+	return true
+}
+
+// mergeGroup merges the variants at a position that have the same alleles.
+func (m *Merger) mergeGroup(group []mergeHead) ([]Variant, error) {
+	var sites [][]mergeHead
+	for _, h := range group {
+		found := false
+		for j, site := range sites {
+			if sameAlleles(site[0].v, h.v) && !containsInput(site, h.input) {
+				sites[j] = append(site, h)
+				found = true
+				break
+			}
+		}
+		if !found {
+			sites = append(sites, []mergeHead{h})
+		}
+	}
+	xs := make([]Variant, len(sites))
+	for i, site := range sites {
+		var err error
+		if xs[i], err = m.mergeSite(site); err != nil {
+			return nil, err
+		}
+	}
+	return xs, nil
+}
+
+func sameAlleles(a, b Variant) bool {
+	return a.Ref == b.Ref && strings.Join(a.Alt, ",") == strings.Join(b.Alt, ",")
+}
+
+func containsInput(site []mergeHead, input int) bool {
+	for _, h := range site {
+		if h.input == input {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSite merges variants with the same alleles from different inputs.
+func (m *Merger) mergeSite(site []mergeHead) (Variant, error) {
+	first := site[0].v
+	x := Variant{
+		Chrom:  first.Chrom,
+		Pos:    first.Pos,
+		Ref:    first.Ref,
+		Alt:    first.Alt,
+		Qual:   ".",
+		Filter: []string{},
+		Info:   make(map[string]string),
+		Format: []string{},
+		header: &m.header,
+	}
+	ids := []string{}
+	for _, h := range site {
+		v := h.v
+		if v.ID != "." && v.ID != "" && !stringSliceContains(ids, v.ID) {
+			ids = append(ids, v.ID)
+		}
+		x.Qual = maxQual(x.Qual, v.Qual)
+		for _, f := range v.Filter {
+			if !stringSliceContains(x.Filter, f) {
+				x.Filter = append(x.Filter, f)
+			}
+		}
+		for k, value := range v.Info {
+			if _, ok := x.Info[k]; !ok {
+				x.Info[k] = value
+			}
+		}
+		for _, f := range v.Format {
+			if !stringSliceContains(x.Format, f) {
+				x.Format = append(x.Format, f)
+			}
+		}
+	}
+	x.ID = "."
+	if len(ids) > 0 {
+		x.ID = strings.Join(ids, ";")
+	}
+	if len(m.header.Samples) == 0 {
+		return x, nil
+	}
+	if len(x.Format) == 0 {
+		x.Format = []string{"GT"}
+	}
+	called := make([]map[string]string, len(m.header.Samples))
+	ploidy := 0
+	for _, h := range site {
+		for j, g := range h.v.genotypes {
+			called[m.offsets[h.input]+j] = g.values
+			if ploidy == 0 {
+				ploidy = g.Ploidy()
+			}
+		}
+	}
+	if ploidy == 0 {
+		ploidy = 2
+	}
+	missingGT := strings.TrimSuffix(strings.Repeat("./", ploidy), "/")
+	for i, name := range m.header.Samples {
+		values := make(map[string]string, len(x.Format))
+		for _, k := range x.Format {
+			value, ok := called[i][k]
+			if !ok {
+				value = "."
+				if k == "GT" {
+					value = missingGT
+				}
+			}
+			values[k] = value
+		}
+		g, err := NewGenotype(name, values)
+		if err != nil {
+			return Variant{}, fmt.Errorf("%s:%d: FORMAT GT of %s: %w", x.Chrom, x.Pos, name, err)
+		}
+		if err := x.AddGenotype(g); err != nil {
+			return Variant{}, err
+		}
+	}
+	setAlleleCounts(&x)
+	return x, nil
+}
+
+// setAlleleCounts recomputes the AC, AN and AF INFO fields of x from its
+// genotypes, as `bcftools merge` does. Only the fields x has are set.
+func setAlleleCounts(x *Variant) {
+	_, hasAC := x.Info["AC"]
+	_, hasAN := x.Info["AN"]
+	_, hasAF := x.Info["AF"]
+	if !hasAC && !hasAN && !hasAF {
+		return
+	}
+	if x.nAlt() == 0 {
+		delete(x.Info, "AC")
+		delete(x.Info, "AF")
+	}
+	ac := make([]int, x.nAlt())
+	an := 0
+	for _, g := range x.genotypes {
+		for _, i := range g.alleleIndexes {
+			if i == MissingAllele {
+				continue
+			}
+			an++
+			if i > 0 && i <= len(ac) {
+				ac[i-1]++
+			}
+		}
+	}
+	acs := make([]string, len(ac))
+	afs := make([]string, len(ac))
+	for i, n := range ac {
+		acs[i] = strconv.Itoa(n)
+		afs[i] = "."
+		if an > 0 {
+			afs[i] = strconv.FormatFloat(float64(n)/float64(an), 'g', 6, 64)
+		}
+	}
+	if hasAC && len(ac) > 0 {
+		x.Info["AC"] = strings.Join(acs, ",")
+	}
+	if hasAN {
+		x.Info["AN"] = strconv.Itoa(an)
+	}
+	if hasAF && len(ac) > 0 {
+		x.Info["AF"] = strings.Join(afs, ",")
+	}
+}
+
+// Variant returns the most recent variant read by Scan.
+func (m *Merger) Variant() Variant {
+	return m.token
+}
+
+// Err returns the first error encountered by the Merger.
+func (m *Merger) Err() error {
+	return m.err
+}
+
+// Close releases the resources held by the scanners of each input. It must
+// be called if merging is abandoned early.
+func (m *Merger) Close() error {
+	var ret error
+	for _, s := range m.scanners {
+		if err := s.Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// contigOrder orders variants by contig, in the order of the contig header
+// lines, and then by position. Contigs missing from the header come after
+// the others, in natural order (chr2 before chr10).
+type contigOrder map[string]int
+
+func newContigOrder(h Header) contigOrder {
+	o := make(contigOrder)
+	for i, l := range h.Contigs() {
+		o[l.ID()] = i
+	}
+	return o
+}
+
+// less returns true if a is before b.
+func (o contigOrder) less(a, b Variant) bool {
+	if a.Chrom != b.Chrom {
+		i, okA := o[a.Chrom]
+		j, okB := o[b.Chrom]
+		switch {
+		case okA && okB:
+			return i < j
+		case okA != okB:
+			return okA
+		}
+		return naturalLess(a.Chrom, b.Chrom)
+	}
+	return a.Pos < b.Pos
+}
+
+// naturalLess compares strings treating runs of digits as numbers.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		i, j := digitPrefix(a), digitPrefix(b)
+		if i > 0 && j > 0 {
+			x, _ := strconv.Atoi(a[:i])
+			y, _ := strconv.Atoi(b[:j])
+			if x != y {
+				return x < y
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// mergeHead is the next variant of an input.
+type mergeHead struct {
+	v     Variant
+	input int
+}
+
+// mergeHeap is a heap of the next variant of each input, ordered by
+// position and then input.
+type mergeHeap struct {
+	xs    []mergeHead
+	order contigOrder
+	// prev holds the variant most recently pushed for each input.
+	prev map[int]Variant
+}
+
+// last returns the variant most recently pushed for input i, if any.
+func (h *mergeHeap) last(i int) *Variant {
+	v, ok := h.prev[i]
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func (h mergeHeap) Len() int { return len(h.xs) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	a, b := h.xs[i], h.xs[j]
+	if h.order.less(a.v, b.v) {
+		return true
+	}
+	if h.order.less(b.v, a.v) {
+		return false
+	}
+	return a.input < b.input
+}
+
+func (h mergeHeap) Swap(i, j int) { h.xs[i], h.xs[j] = h.xs[j], h.xs[i] }
+
+func (h *mergeHeap) Push(x interface{}) {
+	m := x.(mergeHead)
+	if h.prev == nil {
+		h.prev = make(map[int]Variant)
+	}
+	h.prev[m.input] = m.v
+	h.xs = append(h.xs, m)
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	n := len(h.xs)
+	x := h.xs[n-1]
+	h.xs = h.xs[:n-1]
+	return x
+}
@@ -0,0 +1,210 @@
+package vcf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mergeTestVCF1 = `##fileformat=VCFv4.2
+##FILTER=<ID=q10,Description="Quality below 10">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Read depth">
+##contig=<ID=chr2,length=2000>
+##contig=<ID=chr10,length=1000>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+chr2	100	rs1	A	C	20	PASS	DP=10	GT:DP	0/1:10
+chr2	300	.	G	T	30	q10	DP=5	GT:DP	1/1:5
+chr10	50	.	C	G	40	PASS	DP=8	GT:DP	0/1:8
+`
+
+const mergeTestVCF2 = `##fileformat=VCFv4.3
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##contig=<ID=chr2,length=2000>
+##contig=<ID=chr10,length=1000>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S2	S3
+chr2	100	rs2	A	C	30	PASS	DP=20;AF=0.5	GT	0/0	1/1
+chr2	100	.	A	G	10	PASS	DP=20;AF=0.5	GT	0/1	0/0
+chr2	200	.	T	A	.	PASS	DP=7	GT	0/1	./.
+chr10	50	.	C	G	40	PASS	DP=9	GT	0|1	1|1
+chr11	5	.	C	G	40	PASS	DP=9	GT	0/1	0/1
+`
+
+func TestMerger(t *testing.T) {
+	a, err := New(writeTestFile(t, "a.vcf", mergeTestVCF1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(writeTestFile(t, "b.vcf.gz", mergeTestVCF2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMerger(a, b)
+	if err != nil {
+		t.Fatalf("NewMerger() error = %v", err)
+	}
+	h := m.Header()
+	if want := []string{"S1", "S2", "S3"}; !reflect.DeepEqual(h.Samples, want) {
+		t.Errorf("Header().Samples = %v, want %v", h.Samples, want)
+	}
+	if h.Version() != 4.3 {
+		t.Errorf("Header().Version() = %v, want 4.3", h.Version())
+	}
+	if n := len(h.Infos()); n != 2 {
+		t.Errorf("Header() has %d INFO lines, want 2", n)
+	}
+	if n := len(h.Contigs()); n != 2 {
+		t.Errorf("Header() has %d contig lines, want 2", n)
+	}
+	type variant struct {
+		Chrom, ID, Alt, Qual, Filter string
+		Pos                          int
+		Format                       []string
+		Samples                      []string
+	}
+	want := []variant{
+		{"chr2", "rs1;rs2", "C", "30", "", 100, []string{"GT", "DP"}, []string{"0/1:10", "0/0:.", "1/1:."}},
+		{"chr2", ".", "G", "10", "", 100, []string{"GT"}, []string{"./.", "0/1", "0/0"}},
+		{"chr2", ".", "A", ".", "", 200, []string{"GT"}, []string{"./.", "0/1", "./."}},
+		{"chr2", ".", "T", "30", "q10", 300, []string{"GT", "DP"}, []string{"1/1:5", "./.:.", "./.:."}},
+		{"chr10", ".", "G", "40", "", 50, []string{"GT", "DP"}, []string{"0/1:8", "0|1:.", "1|1:."}},
+		{"chr11", ".", "G", "40", "", 5, []string{"GT"}, []string{"./.", "0/1", "0/1"}},
+	}
+	got := []variant{}
+	for m.Scan() {
+		v := m.Variant()
+		samples := []string{}
+		for _, g := range v.Genotypes() {
+			samples = append(samples, g.AsVCFString())
+		}
+		got = append(got, variant{v.Chrom, v.ID, strings.Join(v.Alt, ","), v.Qual, strings.Join(v.Filter, ";"), v.Pos, v.Format, samples})
+	}
+	if err := m.Err(); err != nil {
+		t.Fatalf("Merger.Err() = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged variants:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestMerger_alleleCounts(t *testing.T) {
+	const header = `##fileformat=VCFv4.2
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##INFO=<ID=AN,Number=1,Type=Integer,Description="Allele number">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Genotype likelihoods">
+##contig=<ID=chr1,length=1000>
+##contig=<ID=chrY,length=1000>
+`
+	a, err := New(writeTestFile(t, "a.vcf", header+`#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+chr1	100	.	A	C,G	.	.	AC=1,0;AN=4;AF=0.25,0	GT	0/1	0/0
+chrY	100	.	A	C	.	.	AC=1;AN=2;AF=0.5	GT:PL	1:10,0	0:0,10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(writeTestFile(t, "b.vcf", header+`#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S3
+chr1	100	.	A	C,G	.	.	AC=1,1;AN=2;AF=0.5,0.5	GT	1/2
+chr1	200	.	A	C	.	.	AC=1;AN=2;AF=0.5	GT:PL	0/1:10,0,10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMerger(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type variant struct {
+		Pos        int
+		AC, AN, AF string
+		Samples    []string
+	}
+	want := []variant{
+		{100, "2,1", "6", "0.333333,0.166667", []string{"0/1", "0/0", "1/2"}},
+		{200, "1", "2", "0.5", []string{"./.:.", "./.:.", "0/1:10,0,10"}},
+		{100, "1", "2", "0.5", []string{"1:10,0", "0:0,10", ".:."}},
+	}
+	got := []variant{}
+	for m.Scan() {
+		v := m.Variant()
+		samples := []string{}
+		for _, g := range v.Genotypes() {
+			samples = append(samples, g.AsVCFString())
+		}
+		got = append(got, variant{v.Pos, v.Info["AC"], v.Info["AN"], v.Info["AF"], samples})
+	}
+	if err := m.Err(); err != nil {
+		t.Fatalf("Merger.Err() = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged variants:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestNewMerger_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"samples", strings.Replace(mergeTestVCF2, "S2", "S1", 1), "sample S1"},
+		{"number", strings.Replace(mergeTestVCF2, "ID=DP,Number=1", "ID=DP,Number=A", 1), "INFO DP is defined with Number=1 and Number=A"},
+		{"type", strings.Replace(mergeTestVCF2, "Type=String", "Type=Integer", 1), "FORMAT GT is defined with Type=String and Type=Integer"},
+		{"contig", strings.Replace(mergeTestVCF2, "ID=chr10,length=1000", "ID=chr10,length=999", 1), "contig chr10 is defined with length=1000 and length=999"},
+	}
+	a, err := New(writeTestFile(t, "a.vcf", mergeTestVCF1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(writeTestFile(t, "b.vcf", tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewMerger(a, b); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewMerger() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerger_unsorted(t *testing.T) {
+	a, err := New(writeTestFile(t, "a.vcf", strings.Replace(mergeTestVCF1, "chr2\t300", "chr2\t30", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMerger(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for m.Scan() {
+	}
+	if err := m.Err(); err == nil || !strings.Contains(err.Error(), "not sorted") {
+		t.Errorf("Merger.Err() = %v, want unsorted error", err)
+	}
+}
+
+func Test_naturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"chr2", "chr10", true},
+		{"chr10", "chr2", false},
+		{"chrX", "chrY", true},
+		{"chr1", "chr1_random", true},
+		{"2", "X", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if got := naturalLess(tt.a, tt.b); got != tt.want {
+				t.Errorf("naturalLess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
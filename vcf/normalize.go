@@ -0,0 +1,210 @@
+package vcf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reference is a reference genome. hts.Fasta is a Reference.
+type Reference interface {
+	// Query returns the bases of contig from start to end, where start
+	// and end are 0-based and end is exclusive.
+	Query(contig string, start, end int) (string, error)
+}
+
+// NormStatus is the result of normalising a variant.
+type NormStatus int
+
+const (
+	// NormUnchanged is a variant that was already normalised.
+	NormUnchanged NormStatus = iota
+	// NormChanged is a variant that was left-aligned or trimmed.
+	NormChanged
+	// NormRefMismatch is a variant whose REF allele does not match the
+	// reference. It is not normalised.
+	NormRefMismatch
+	// NormSkipped is a variant with symbolic, breakend or missing alleles,
+	// or whose alleles are all the same, that can not be normalised.
+	NormSkipped
+)
+
+func (s NormStatus) String() string {
+	switch s {
+	case NormUnchanged:
+		return "unchanged"
+	case NormChanged:
+		return "changed"
+	case NormRefMismatch:
+		return "REF mismatch"
+	case NormSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("NormStatus(%d)", int(s))
+}
+
+// NormStats counts the variants normalised by a Normalizer by their
+// NormStatus.
+type NormStats struct {
+	Total       int
+	Changed     int
+	RefMismatch int
+	Skipped     int
+}
+
+// Normalizer left-aligns and trims variants against a reference, in the
+// same way as `bcftools norm` and `vt normalize`, so that the same event is
+// always represented in the same way.
+type Normalizer struct {
+	ref   Reference
+	stats NormStats
+}
+
+// NewNormalizer creates a Normalizer that uses the reference ref.
+func NewNormalizer(ref Reference) *Normalizer {
+	return &Normalizer{ref: ref}
+}
+
+// Stats returns the number of variants normalised so far.
+func (n *Normalizer) Stats() NormStats {
+	return n.stats
+}
+
+// Normalize returns v left-aligned and parsimoniously trimmed: shifted as
+// far left as possible, with no bases in common at the end of all alleles
+// and at most one base (the padding base needed for indels) in common at
+// the start. A shift to the left also moves INFO/END. The alleles of a
+// changed variant are upper case, but a variant that differs only in case is
+// unchanged. The status reports whether v was changed. Variants whose REF
+// allele does not match the reference, that have symbolic alleles, or whose
+// alleles are all the same, are returned unchanged. An error is returned if
+// the reference can not be read.
+func (n *Normalizer) Normalize(v Variant) (Variant, NormStatus, error) {
+	status, err := n.normalize(&v)
+	if err != nil {
+		return v, status, fmt.Errorf("unable to normalise %s:%d: %w", v.Chrom, v.Pos, err)
+	}
+	n.stats.Total++
+	switch status {
+	case NormChanged:
+		n.stats.Changed++
+	case NormRefMismatch:
+		n.stats.RefMismatch++
+	case NormSkipped:
+		n.stats.Skipped++
+	}
+	return v, status, nil
+}
+
+func (n *Normalizer) normalize(v *Variant) (NormStatus, error) {
+	if v.nAlt() == 0 {
+		return NormSkipped, nil
+	}
+	for _, a := range v.Alleles() {
+		if a == "" || biallelicType(v.Ref, a) == SYMBOLIC || strings.Contains(a, ".") {
+			return NormSkipped, nil
+		}
+	}
+	// Identical alleles share every base, so trimming them would walk to
+	// the start of the contig.
+	if allSame(v.Alleles()) {
+		return NormSkipped, nil
+	}
+	seq, err := n.ref.Query(v.Chrom, v.Pos-1, v.Pos-1+len(v.Ref))
+	if err != nil {
+		return NormUnchanged, err
+	}
+	if !strings.EqualFold(seq, v.Ref) {
+		return NormRefMismatch, nil
+	}
+	pos := v.Pos
+	alleles := v.Alleles()
+	for i := range alleles {
+		alleles[i] = strings.ToUpper(alleles[i])
+	}
+	for {
+		// Remove a common last base, first extending the alleles to
+		// the left if this would leave an empty allele.
+		if !sameBase(alleles, func(a string) byte { return a[len(a)-1] }) {
+			break
+		}
+		if minLen(alleles) == 1 {
+			if pos == 1 {
+				break
+			}
+			b, err := n.ref.Query(v.Chrom, pos-2, pos-1)
+			if err != nil {
+				return NormUnchanged, err
+			}
+			for i := range alleles {
+				alleles[i] = strings.ToUpper(b) + alleles[i]
+			}
+			pos--
+		}
+		for i := range alleles {
+			alleles[i] = alleles[i][:len(alleles[i])-1]
+		}
+	}
+	// Remove common leading bases, keeping at least one base.
+	for minLen(alleles) > 1 && sameBase(alleles, func(a string) byte { return a[0] }) {
+		for i := range alleles {
+			alleles[i] = alleles[i][1:]
+		}
+		pos++
+	}
+	changed := pos != v.Pos
+	for i, a := range v.Alleles() {
+		if !strings.EqualFold(a, alleles[i]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return NormUnchanged, nil
+	}
+	if pos != v.Pos && v.HasAttribute("END") {
+		end, err := v.AttributeAsInt("END")
+		if err != nil {
+			return NormUnchanged, v.infoError("END", err)
+		}
+		info := make(map[string]string, len(v.Info))
+		for k, x := range v.Info {
+			info[k] = x
+		}
+		info["END"] = strconv.Itoa(end + pos - v.Pos)
+		v.Info = info
+	}
+	v.Pos = pos
+	v.Ref = alleles[0]
+	v.Alt = alleles[1:]
+	return NormChanged, nil
+}
+
+// sameBase returns true if base returns the same base for every allele.
+func sameBase(alleles []string, base func(string) byte) bool {
+	for _, a := range alleles[1:] {
+		if base(a) != base(alleles[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// allSame returns true if the alleles are all the same, ignoring case.
+func allSame(alleles []string) bool {
+	for _, a := range alleles[1:] {
+		if !strings.EqualFold(a, alleles[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func minLen(alleles []string) int {
+	n := len(alleles[0])
+	for _, a := range alleles[1:] {
+		if len(a) < n {
+			n = len(a)
+		}
+	}
+	return n
+}
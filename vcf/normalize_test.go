@@ -0,0 +1,152 @@
+package vcf
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testReference is a Reference held in memory.
+type testReference map[string]string
+
+func (r testReference) Query(contig string, start, end int) (string, error) {
+	seq, ok := r[contig]
+	if !ok {
+		return "", fmt.Errorf("no such contig: %s", contig)
+	}
+	if start < 0 || end > len(seq) || start > end {
+		return "", fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	return seq[start:end], nil
+}
+
+func TestNormalizer_Normalize(t *testing.T) {
+	ref := testReference{"1": "TCACACAGGG"}
+	tests := []struct {
+		name    string
+		v       Variant
+		want    Variant
+		status  NormStatus
+		wantErr bool
+	}{
+		{
+			"left align deletion",
+			Variant{Chrom: "1", Pos: 5, Ref: "ACA", Alt: []string{"A"}},
+			Variant{Chrom: "1", Pos: 1, Ref: "TCA", Alt: []string{"T"}},
+			NormChanged, false,
+		},
+		{
+			"left align insertion",
+			Variant{Chrom: "1", Pos: 7, Ref: "A", Alt: []string{"ACA"}},
+			Variant{Chrom: "1", Pos: 1, Ref: "T", Alt: []string{"TCA"}},
+			NormChanged, false,
+		},
+		{
+			"normalised",
+			Variant{Chrom: "1", Pos: 1, Ref: "TCA", Alt: []string{"T"}},
+			Variant{Chrom: "1", Pos: 1, Ref: "TCA", Alt: []string{"T"}},
+			NormUnchanged, false,
+		},
+		{
+			"trim right",
+			Variant{Chrom: "1", Pos: 2, Ref: "CA", Alt: []string{"TA"}},
+			Variant{Chrom: "1", Pos: 2, Ref: "C", Alt: []string{"T"}},
+			NormChanged, false,
+		},
+		{
+			"trim left",
+			Variant{Chrom: "1", Pos: 1, Ref: "TCA", Alt: []string{"TCG"}},
+			Variant{Chrom: "1", Pos: 3, Ref: "A", Alt: []string{"G"}},
+			NormChanged, false,
+		},
+		{
+			"multi-allelic",
+			Variant{Chrom: "1", Pos: 5, Ref: "ACA", Alt: []string{"A", "ACACA"}},
+			Variant{Chrom: "1", Pos: 1, Ref: "TCA", Alt: []string{"T", "TCACA"}},
+			NormChanged, false,
+		},
+		{
+			"lower case",
+			Variant{Chrom: "1", Pos: 8, Ref: "g", Alt: []string{"ggg"}},
+			Variant{Chrom: "1", Pos: 7, Ref: "A", Alt: []string{"AGG"}},
+			NormChanged, false,
+		},
+		{
+			"lower case only",
+			Variant{Chrom: "1", Pos: 2, Ref: "c", Alt: []string{"t"}},
+			Variant{Chrom: "1", Pos: 2, Ref: "c", Alt: []string{"t"}},
+			NormUnchanged, false,
+		},
+		{
+			"END",
+			Variant{Chrom: "1", Pos: 5, Ref: "ACA", Alt: []string{"A"}, Info: map[string]string{"END": "7"}},
+			Variant{Chrom: "1", Pos: 1, Ref: "TCA", Alt: []string{"T"}, Info: map[string]string{"END": "3"}},
+			NormChanged, false,
+		},
+		{
+			"invalid END",
+			Variant{Chrom: "1", Pos: 5, Ref: "ACA", Alt: []string{"A"}, Info: map[string]string{"END": "x"}},
+			Variant{},
+			NormUnchanged, true,
+		},
+		{
+			"ref mismatch",
+			Variant{Chrom: "1", Pos: 1, Ref: "G", Alt: []string{"A"}},
+			Variant{Chrom: "1", Pos: 1, Ref: "G", Alt: []string{"A"}},
+			NormRefMismatch, false,
+		},
+		{
+			"symbolic",
+			Variant{Chrom: "1", Pos: 1, Ref: "T", Alt: []string{"<DEL>"}},
+			Variant{Chrom: "1", Pos: 1, Ref: "T", Alt: []string{"<DEL>"}},
+			NormSkipped, false,
+		},
+		{
+			"identical alleles",
+			Variant{Chrom: "1", Pos: 8, Ref: "G", Alt: []string{"g"}},
+			Variant{Chrom: "1", Pos: 8, Ref: "G", Alt: []string{"g"}},
+			NormSkipped, false,
+		},
+		{
+			"unknown contig",
+			Variant{Chrom: "2", Pos: 1, Ref: "T", Alt: []string{"C"}},
+			Variant{},
+			NormUnchanged, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNormalizer(ref)
+			got, status, err := n.Normalize(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalizer.Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if status != tt.status {
+				t.Errorf("Normalizer.Normalize() status = %v, want %v", status, tt.status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Normalizer.Normalize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizer_Stats(t *testing.T) {
+	n := NewNormalizer(testReference{"1": "TCACACAGGG"})
+	for _, v := range []Variant{
+		{Chrom: "1", Pos: 5, Ref: "ACA", Alt: []string{"A"}},
+		{Chrom: "1", Pos: 1, Ref: "T", Alt: []string{"C"}},
+		{Chrom: "1", Pos: 1, Ref: "G", Alt: []string{"C"}},
+		{Chrom: "1", Pos: 2, Ref: "C", Alt: []string{"<INS>"}},
+	} {
+		if _, _, err := n.Normalize(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := n.Stats(), (NormStats{Total: 4, Changed: 1, RefMismatch: 1, Skipped: 1}); got != want {
+		t.Errorf("Normalizer.Stats() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package vcf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPos is the end of a region that extends to the end of its contig.
+const maxPos = 1<<31 - 1
+
+// Region is a genomic interval. Start and End are 1-based and inclusive.
+type Region struct {
+	Chrom string
+	Start int
+	End   int
+}
+
+// ParseRegion parses a region string in the same format as bcftools:
+// "chr1" (the whole contig), "chr1:100" (a single position), "chr1:100-"
+// (position 100 to the end of the contig) or "chr1:100-200". Commas in
+// positions are ignored.
+func ParseRegion(s string) (Region, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Region{}, fmt.Errorf("empty region")
+	}
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Region{Chrom: s, Start: 1, End: maxPos}, nil
+	}
+	chrom, span := s[:i], strings.Replace(s[i+1:], ",", "", -1)
+	bits := strings.SplitN(span, "-", 2)
+	start, err := strconv.Atoi(bits[0])
+	if err != nil {
+		// Contig names are allowed to contain colons. The text after
+		// the colon is not a position so this is a whole contig.
+		return Region{Chrom: s, Start: 1, End: maxPos}, nil
+	}
+	end := start
+	if len(bits) == 2 {
+		if bits[1] == "" {
+			end = maxPos
+		} else {
+			end, err = strconv.Atoi(bits[1])
+			if err != nil {
+				return Region{}, fmt.Errorf("invalid region %s: %w", s, err)
+			}
+		}
+	}
+	if chrom == "" || start < 1 || end < start {
+		return Region{}, fmt.Errorf("invalid region %s", s)
+	}
+	return Region{Chrom: chrom, Start: start, End: end}, nil
+}
+
+func parseRegions(locs []string) ([]Region, error) {
+	regions := []Region{}
+	for _, loc := range locs {
+		r, err := ParseRegion(loc)
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, r)
+	}
+	return regions, nil
+}
+
+// String returns the region in the format accepted by ParseRegion.
+func (r Region) String() string {
+	if r.Start <= 1 && r.End >= maxPos {
+		return r.Chrom
+	}
+	if r.End >= maxPos {
+		return fmt.Sprintf("%s:%d-", r.Chrom, r.Start)
+	}
+	if r.Start == r.End {
+		return fmt.Sprintf("%s:%d", r.Chrom, r.Start)
+	}
+	return fmt.Sprintf("%s:%d-%d", r.Chrom, r.Start, r.End)
+}
+
+// Overlaps returns true if any part of v lies within the region. A variant
+// covers the reference bases from its Pos to its end: the last base of the
+// REF allele or, if the variant has an END INFO field, END. This is the same
+// rule used by tabix and bcftools, so a deletion overlaps a region that only
+// contains its deleted bases, while an insertion only overlaps a region
+// containing its anchor base. Symbolic DEL, DUP, INV and CNV alleles without
+// END span SVLEN bases after Pos, other symbolic alleles span their REF
+// allele.
+func (r Region) Overlaps(v Variant) bool {
+	return v.Overlaps(r.Chrom, r.Start, r.End)
+}
+
+func overlapsAny(regions []Region, v Variant) bool {
+	for _, r := range regions {
+		if r.Overlaps(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package vcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Region
+		wantErr bool
+	}{
+		{"t1", "chr1", Region{"chr1", 1, maxPos}, false},
+		{"t2", "chr1:100", Region{"chr1", 100, 100}, false},
+		{"t3", "chr1:100-200", Region{"chr1", 100, 200}, false},
+		{"t4", "chr1:10,000-20,000", Region{"chr1", 10000, 20000}, false},
+		{"t5", "chr1:100-", Region{"chr1", 100, maxPos}, false},
+		{"t6", "chrUn:KI270302v1", Region{"chrUn:KI270302v1", 1, maxPos}, false},
+		{"t7", "", Region{}, true},
+		{"t8", "chr1:200-100", Region{}, true},
+		{"t9", "chr1:0-100", Region{}, true},
+		{"t10", "chr1:100-x", Region{}, true},
+		{"t11", ":100-200", Region{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRegion(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRegion() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRegion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegion_Overlaps(t *testing.T) {
+	snp := Variant{Chrom: "1", Pos: 100, Ref: "A", Alt: []string{"C"}}
+	del := Variant{Chrom: "1", Pos: 100, Ref: "ATG", Alt: []string{"A"}}
+	ins := Variant{Chrom: "1", Pos: 100, Ref: "A", Alt: []string{"ATG"}}
+	sv := Variant{Chrom: "1", Pos: 100, Ref: "A", Alt: []string{"<DEL>"}, Info: map[string]string{"END": "500"}}
+	tests := []struct {
+		name   string
+		region Region
+		v      Variant
+		want   bool
+	}{
+		{"snp inside", Region{"1", 50, 150}, snp, true},
+		{"snp at start", Region{"1", 100, 150}, snp, true},
+		{"snp at end", Region{"1", 50, 100}, snp, true},
+		{"snp before", Region{"1", 101, 150}, snp, false},
+		{"snp after", Region{"1", 50, 99}, snp, false},
+		{"other contig", Region{"2", 50, 150}, snp, false},
+		{"deleted bases", Region{"1", 102, 150}, del, true},
+		{"after deletion", Region{"1", 103, 150}, del, false},
+		{"insertion anchor", Region{"1", 100, 100}, ins, true},
+		{"after insertion", Region{"1", 101, 150}, ins, false},
+		{"symbolic END", Region{"1", 400, 450}, sv, true},
+		{"after symbolic END", Region{"1", 501, 600}, sv, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.region.Overlaps(tt.v); got != tt.want {
+				t.Errorf("Region.Overlaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewScanner_regions(t *testing.T) {
+	path := writeTestFile(t, "test.vcf.gz", testVCF)
+	v, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		loc     []string
+		want    []int
+		wantErr bool
+	}{
+		{"none", nil, []int{100, 200, 50}, false},
+		{"contig", []string{"1"}, []int{100, 200}, false},
+		{"position", []string{"1:100"}, []int{100}, false},
+		{"deleted base", []string{"1:201-300"}, []int{200}, false},
+		{"multiple", []string{"2", "1:150-250"}, []int{200, 50}, false},
+		{"overlapping regions", []string{"1", "1:100"}, []int{100, 200}, false},
+		{"no variants", []string{"1:300-400"}, []int{}, false},
+		{"unknown contig", []string{"3"}, []int{}, false},
+		{"invalid", []string{"1:200-100"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScanner(v, tt.loc...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewScanner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := []int{}
+			for _, v := range scanAll(t, s) {
+				got = append(got, v.Pos)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("positions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package vcf
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// DefaultSortMemory is the memory limit of a Sorter created with a limit of
+// zero.
+const DefaultSortMemory = 256 << 20
+
+// Sorter sorts variants by contig, in the order of the contig header lines
+// (contigs missing from the header come after the others, in natural
+// order), and then by position. Variants at the same position are kept in
+// the order they were added. When the variants held in memory exceed the
+// memory limit they are sorted and written to a temporary file, and the
+// files are merged as the sorted variants are read.
+type Sorter struct {
+	header  Header
+	order   contigOrder
+	memory  int
+	buf     []Variant
+	size    int
+	dir     string
+	runs    []string
+	readers []*fileReader
+	heads   mergeHeap
+	sources []recordReader
+	scanned bool
+	token   Variant
+	err     error
+}
+
+// NewSorter creates a Sorter for variants with the header h that holds
+// about memory bytes of variants in memory, or DefaultSortMemory if memory
+// is zero.
+func NewSorter(h Header, memory int) *Sorter {
+	if memory <= 0 {
+		memory = DefaultSortMemory
+	}
+	return &Sorter{header: h, order: newContigOrder(h), memory: memory}
+}
+
+// Add adds a variant to be sorted. It must not be called after Scan.
+func (s *Sorter) Add(v Variant) error {
+	if s.scanned {
+		return errors.New("variant added to Sorter after Scan")
+	}
+	s.buf = append(s.buf, v)
+	s.size += variantSize(v)
+	if s.size > s.memory {
+		return s.spill()
+	}
+	return nil
+}
+
+// variantSize returns the approximate memory used by v.
+func variantSize(v Variant) int {
+	n := 200 + len(v.Chrom) + len(v.ID) + len(v.Ref) + len(v.Qual)
+	for _, a := range v.Alt {
+		n += len(a) + 16
+	}
+	for k, x := range v.Info {
+		n += len(k) + len(x) + 32
+	}
+	for _, g := range v.genotypes {
+		n += len(g.Name) + 100
+		for k, x := range g.values {
+			n += len(k) + len(x) + 32
+		}
+	}
+	return n
+}
+
+// sortBuffer sorts the variants held in memory.
+func (s *Sorter) sortBuffer() {
+	sort.SliceStable(s.buf, func(i, j int) bool {
+		return s.order.less(s.buf[i], s.buf[j])
+	})
+}
+
+// spill writes the variants held in memory to a temporary file.
+func (s *Sorter) spill() error {
+	s.sortBuffer()
+	if s.dir == "" {
+		dir, err := ioutil.TempDir("", "vcf-sort")
+		if err != nil {
+			return fmt.Errorf("unable to create temporary directory: %w", err)
+		}
+		s.dir = dir
+	}
+	f, err := ioutil.TempFile(s.dir, "run-*.vcf.gz")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	s.runs = append(s.runs, f.Name())
+	gz, _ := gzip.NewWriterLevel(f, gzip.BestSpeed)
+	bw := bufio.NewWriter(gz)
+	for _, v := range s.buf {
+		bw.WriteString(v.AsVCFLine())
+		bw.WriteByte('\n')
+	}
+	err = bw.Flush()
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write temporary file: %w", err)
+	}
+	s.buf = nil
+	s.size = 0
+	return nil
+}
+
+// start sorts the variants held in memory and opens the temporary files
+// ready to merge them.
+func (s *Sorter) start() error {
+	s.sortBuffer()
+	for _, run := range s.runs {
+		f, err := openFile(run)
+		if err != nil {
+			return err
+		}
+		s.readers = append(s.readers, f)
+		s.sources = append(s.sources, newTextReader(f, s.header.Samples, &readerConfig{}))
+	}
+	s.sources = append(s.sources, &sliceReader{xs: s.buf})
+	s.buf = nil
+	s.heads = mergeHeap{order: s.order}
+	for i := range s.sources {
+		if err := s.advance(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advance reads the next variant of source i onto the heap.
+func (s *Sorter) advance(i int) error {
+	v, err := s.sources[i].read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read temporary file: %w", err)
+	}
+	heap.Push(&s.heads, mergeHead{v, i})
+	return nil
+}
+
+// Scan advances to the next sorted variant, which is then available from
+// the Variant method. It returns false when there are no more variants or
+// an error occurs.
+func (s *Sorter) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.scanned {
+		s.scanned = true
+		if s.err = s.start(); s.err != nil {
+			return false
+		}
+	}
+	if len(s.heads.xs) == 0 {
+		return false
+	}
+	h := heap.Pop(&s.heads).(mergeHead)
+	if s.err = s.advance(h.input); s.err != nil {
+		return false
+	}
+	s.token = h.v
+	s.token.header = &s.header
+	return true
+}
+
+// Variant returns the most recent variant read by Scan.
+func (s *Sorter) Variant() Variant {
+	return s.token
+}
+
+// Err returns the first error encountered by the Sorter.
+func (s *Sorter) Err() error {
+	return s.err
+}
+
+// Close removes the temporary files used by the Sorter.
+func (s *Sorter) Close() error {
+	var ret error
+	for _, r := range s.readers {
+		if err := r.Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	s.readers = nil
+	if s.dir != "" {
+		if err := os.RemoveAll(s.dir); err != nil && ret == nil {
+			ret = err
+		}
+		s.dir = ""
+	}
+	return ret
+}
+
+// sliceReader is a recordReader for variants held in memory.
+type sliceReader struct {
+	xs []Variant
+}
+
+func (r *sliceReader) read() (Variant, error) {
+	if len(r.xs) == 0 {
+		return Variant{}, io.EOF
+	}
+	v := r.xs[0]
+	r.xs = r.xs[1:]
+	return v, nil
+}
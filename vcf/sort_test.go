@@ -0,0 +1,117 @@
+package vcf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sortTestVCF = `##fileformat=VCFv4.2
+##INFO=<ID=N,Number=1,Type=Integer,Description="Input order">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##contig=<ID=chrX,length=1000>
+##contig=<ID=chr1,length=1000>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+chr1	300	.	A	C	.	PASS	N=1	GT	0/1
+chr10	5	.	A	C	.	PASS	N=2	GT	0/1
+chrX	500	.	A	C	.	PASS	N=3	GT	1/1
+chr1	100	.	A	C	.	PASS	N=4	GT	0/1
+chr2	5	.	A	C	.	PASS	N=5	GT	0/1
+chr1	100	.	A	G	.	PASS	N=6	GT	0/0
+chrX	20	.	A	C	.	PASS	N=7	GT	0/1
+`
+
+func TestSorter(t *testing.T) {
+	tests := []struct {
+		name   string
+		memory int
+		spills bool
+	}{
+		{"memory", 0, false},
+		{"spill", 1, true},
+		{"spill some", 700, true},
+	}
+	want := []string{"chrX:20:7", "chrX:500:3", "chr1:100:4", "chr1:100:6", "chr1:300:1", "chr2:5:5", "chr10:5:2"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(sortTestVCF))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewSorter(r.Header(), tt.memory)
+			defer s.Close()
+			for _, v := range scanAll(t, r) {
+				if err := s.Add(v); err != nil {
+					t.Fatalf("Sorter.Add() error = %v", err)
+				}
+			}
+			if got := len(s.runs) > 0; got != tt.spills {
+				t.Errorf("Sorter spilled = %v, want %v", got, tt.spills)
+			}
+			dir := s.dir
+			got := []string{}
+			for s.Scan() {
+				v := s.Variant()
+				gt, _ := v.genotypes[0].Attribute("GT")
+				if v.header == nil || gt == "" {
+					t.Errorf("variant %s:%d has lost its header or genotypes", v.Chrom, v.Pos)
+				}
+				got = append(got, fmt.Sprintf("%s:%d:%s", v.Chrom, v.Pos, v.Info["N"]))
+			}
+			if err := s.Err(); err != nil {
+				t.Fatalf("Sorter.Err() = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Sorter variants = %v, want %v", got, want)
+			}
+			if err := s.Add(Variant{}); err == nil {
+				t.Error("Sorter.Add() after Scan expected error")
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Sorter.Close() error = %v", err)
+			}
+			if dir != "" {
+				if _, err := os.Stat(dir); !os.IsNotExist(err) {
+					t.Errorf("temporary directory %s not removed", dir)
+				}
+			}
+		})
+	}
+}
+
+func TestSorter_longLines(t *testing.T) {
+	h := NewHeader()
+	h.Samples = []string{"S1"}
+	// A record longer than 100 KB, the limit of a default bufio.Scanner.
+	long := strings.Repeat("A", 200000)
+	s := NewSorter(h, 1)
+	defer s.Close()
+	for _, pos := range []int{200, 100} {
+		v, err := parseVcfLine(fmt.Sprintf("1\t%d\t.\tA\tC\t.\t.\tX=%s\tGT\t0/1", pos, long), h.Samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add(v); err != nil {
+			t.Fatalf("Sorter.Add() error = %v", err)
+		}
+	}
+	if len(s.runs) == 0 {
+		t.Fatal("Sorter did not spill")
+	}
+	got := []int{}
+	for s.Scan() {
+		v := s.Variant()
+		if v.Info["X"] != long {
+			t.Errorf("variant %s:%d INFO X has length %d, want %d", v.Chrom, v.Pos, len(v.Info["X"]), len(long))
+		}
+		got = append(got, v.Pos)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Sorter.Err() = %v", err)
+	}
+	if want := []int{100, 200}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sorter positions = %v, want %v", got, want)
+	}
+}
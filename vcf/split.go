@@ -0,0 +1,186 @@
+package vcf
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Split returns one biallelic variant for each alternate allele of v, in the
+// same way as `bcftools norm -m-`. INFO and FORMAT fields declared in the
+// header with Number=A, R or G are subset to the values for the reference
+// and the alternate allele of each variant, other fields are copied
+// unchanged. Genotypes are remapped so that the alternate allele has index 1
+// and any other alternate allele becomes the reference allele. A variant
+// with fewer than two alternate alleles is returned unchanged.
+func (v Variant) Split() ([]Variant, error) {
+	if v.nAlt() < 2 {
+		return []Variant{v}, nil
+	}
+	xs := make([]Variant, 0, len(v.Alt))
+	for i := 1; i <= len(v.Alt); i++ {
+		x, err := v.subsetAlleles([]int{0, i})
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	return xs, nil
+}
+
+// subsetAlleles returns a copy of v with only the alleles in alleleMap,
+// where alleleMap[i] is the index in v of the i'th allele of the copy. The
+// reference allele must be kept as the first allele. Genotypes with alleles
+// that are not kept are given the reference allele instead.
+func (v Variant) subsetAlleles(alleleMap []int) (Variant, error) {
+	alleles := v.Alleles()
+	x := v
+	x.Alt = make([]string, 0, len(alleleMap)-1)
+	for _, i := range alleleMap[1:] {
+		x.Alt = append(x.Alt, alleles[i])
+	}
+	x.Info = make(map[string]string, len(v.Info))
+	for k, value := range v.Info {
+		// Number=G in INFO fields assumes diploid samples.
+		subset, err := subsetValues(v.definition("INFO", k), value, alleleMap, v.nAlt(), 2)
+		if err != nil {
+			return Variant{}, v.infoError(k, err)
+		}
+		x.Info[k] = subset
+	}
+	x.genotypes = nil
+	for _, g := range v.Genotypes() {
+		values := make(map[string]string, len(g.values))
+		for k, value := range g.values {
+			if k == "GT" {
+				values[k] = remapGenotype(value, alleleMap)
+				continue
+			}
+			def := v.definition("FORMAT", k)
+			subset, err := subsetValues(def, value, alleleMap, v.nAlt(), g.fieldPloidy(def, value, v.nAlt()))
+			if err != nil {
+				return Variant{}, g.formatError(k, err)
+			}
+			values[k] = subset
+		}
+		ng, err := NewGenotype(g.Name, values)
+		if err != nil {
+			return Variant{}, g.formatError("GT", err)
+		}
+		if err := x.AddGenotype(ng); err != nil {
+			return Variant{}, err
+		}
+	}
+	return x, nil
+}
+
+// subsetValues returns the values of a field declared by def for the
+// alleles in alleleMap (see subsetAlleles). Fields without a definition or
+// that do not have per-allele values are returned unchanged.
+func subsetValues(def *HeaderLine, value string, alleleMap []int, nAlt, ploidy int) (string, error) {
+	if def == nil || value == "." {
+		return value, nil
+	}
+	number := def.Get("Number")
+	if number != "A" && number != "R" && number != "G" {
+		return value, nil
+	}
+	xs, err := splitValues(def, value, nAlt, ploidy)
+	if err != nil {
+		return "", err
+	}
+	ys := []string{}
+	switch number {
+	case "A":
+		for _, i := range alleleMap[1:] {
+			ys = append(ys, xs[i-1])
+		}
+	case "R":
+		for _, i := range alleleMap {
+			ys = append(ys, xs[i])
+		}
+	case "G":
+		for _, gt := range genotypeOrder(len(alleleMap), ploidy) {
+			old := make([]int, len(gt))
+			for j, a := range gt {
+				old[j] = alleleMap[a]
+			}
+			ys = append(ys, xs[genotypeIndex(old)])
+		}
+	}
+	return strings.Join(ys, ","), nil
+}
+
+// remapGenotype rewrites the allele indexes of a GT value for the alleles
+// in alleleMap (see subsetAlleles), keeping missing alleles and phasing.
+func remapGenotype(gt string, alleleMap []int) string {
+	newIndex := make(map[string]string, len(alleleMap))
+	for i, old := range alleleMap {
+		newIndex[strconv.Itoa(old)] = strconv.Itoa(i)
+	}
+	var b strings.Builder
+	start := 0
+	for i := 0; i <= len(gt); i++ {
+		if i < len(gt) && gt[i] != '/' && gt[i] != '|' {
+			continue
+		}
+		allele := gt[start:i]
+		if allele != "." && allele != "" {
+			var ok bool
+			if allele, ok = newIndex[allele]; !ok {
+				allele = "0"
+			}
+		}
+		b.WriteString(allele)
+		if i < len(gt) {
+			b.WriteByte(gt[i])
+		}
+		start = i + 1
+	}
+	return b.String()
+}
+
+// genotypeOrder returns the genotypes of the given ploidy that can be made
+// from n alleles, in the order of Number=G fields. Each genotype is a
+// sorted slice of allele indexes.
+func genotypeOrder(n, ploidy int) [][]int {
+	xs := make([][]int, numGenotypes(n, ploidy))
+	gt := make([]int, ploidy)
+	var fill func(m, min int)
+	fill = func(m, min int) {
+		if m == ploidy {
+			xs[genotypeIndex(gt)] = append([]int{}, gt...)
+			return
+		}
+		for a := min; a < n; a++ {
+			gt[m] = a
+			fill(m+1, a)
+		}
+	}
+	fill(0, 0)
+	return xs
+}
+
+// genotypeIndex returns the index of the genotype with the given alleles in
+// a Number=G field. For a diploid genotype j/k with j <= k this is
+// k*(k+1)/2 + j.
+func genotypeIndex(alleles []int) int {
+	xs := append([]int{}, alleles...)
+	sort.Ints(xs)
+	i := 0
+	for m, a := range xs {
+		i += binomial(a+m, m+1)
+	}
+	return i
+}
+
+func binomial(n, k int) int {
+	if k < 0 || k > n {
+		return 0
+	}
+	x := 1
+	for i := 1; i <= k; i++ {
+		x = x * (n - k + i) / i
+	}
+	return x
+}
@@ -0,0 +1,142 @@
+package vcf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const splitTestVCF = `##fileformat=VCFv4.2
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##INFO=<ID=RAF,Number=R,Type=Float,Description="Allele frequencies">
+##INFO=<ID=GL,Number=G,Type=Float,Description="Genotype likelihoods">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled genotype likelihoods">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3
+1	100	rs1	A	C,G	50	PASS	DP=20;AC=1,2;RAF=0.25,0.25,0.5;GL=0,1,2,3,4,5	GT:AD:PL	1/2:0,10,10:60,50,40,30,20,10	0|2:5,.,5:0,1,2,3,4,5	2:1,0,9:7,8,9
+1	200	.	A	C	.	PASS	AC=1	GT:AD:PL	0/1:5,5:10,0,10	0/0:10,0:0,10,100	./.:.:.
+`
+
+func TestVariant_Split(t *testing.T) {
+	s, err := NewReader(strings.NewReader(splitTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := scanAll(t, s)
+	xs, err := vs[0].Split()
+	if err != nil {
+		t.Fatalf("Variant.Split() error = %v", err)
+	}
+	if len(xs) != 2 {
+		t.Fatalf("Variant.Split() returned %d variants, want 2", len(xs))
+	}
+	type sample struct {
+		GT, AD, PL string
+	}
+	tests := []struct {
+		name    string
+		alt     []string
+		info    map[string]string
+		samples []sample
+	}{
+		{
+			"first",
+			[]string{"C"},
+			map[string]string{"DP": "20", "AC": "1", "RAF": "0.25,0.25", "GL": "0,1,2"},
+			[]sample{{"1/0", "0,10", "60,50,40"}, {"0|0", "5,.", "0,1,2"}, {"0", "1,0", "7,8"}},
+		},
+		{
+			"second",
+			[]string{"G"},
+			map[string]string{"DP": "20", "AC": "2", "RAF": "0.25,0.5", "GL": "0,3,5"},
+			[]sample{{"0/1", "0,10", "60,30,10"}, {"0|1", "5,5", "0,3,5"}, {"1", "1,9", "7,9"}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := xs[i]
+			if x.Chrom != "1" || x.Pos != 100 || x.ID != "rs1" || x.Ref != "A" || x.Qual != "50" {
+				t.Errorf("fixed fields changed: %+v", x)
+			}
+			if !reflect.DeepEqual(x.Alt, tt.alt) {
+				t.Errorf("Alt = %v, want %v", x.Alt, tt.alt)
+			}
+			if !reflect.DeepEqual(x.Info, tt.info) {
+				t.Errorf("Info = %v, want %v", x.Info, tt.info)
+			}
+			got := []sample{}
+			for _, g := range x.Genotypes() {
+				got = append(got, sample{g.values["GT"], g.values["AD"], g.values["PL"]})
+			}
+			if !reflect.DeepEqual(got, tt.samples) {
+				t.Errorf("samples = %v, want %v", got, tt.samples)
+			}
+		})
+	}
+	// The original variant is not modified.
+	if got := vs[0].Info["AC"]; got != "1,2" {
+		t.Errorf("original variant modified, AC = %v", got)
+	}
+	g, _ := xs[1].Sample("S1")
+	if alleles, err := g.Alleles(); err != nil || !reflect.DeepEqual(alleles, []string{"A", "G"}) {
+		t.Errorf("Genotype.Alleles() = %v, %v, want [A G]", alleles, err)
+	}
+	xs, err = vs[1].Split()
+	if err != nil || len(xs) != 1 || !reflect.DeepEqual(xs[0].Info, vs[1].Info) {
+		t.Errorf("Variant.Split() of a biallelic variant = %v, %v", xs, err)
+	}
+}
+
+func TestVariant_Split_invalid(t *testing.T) {
+	s, err := NewReader(strings.NewReader(strings.Replace(splitTestVCF, "AC=1,2", "AC=1", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := scanAll(t, s)
+	if _, err := vs[0].Split(); err == nil || !strings.Contains(err.Error(), "INFO AC") {
+		t.Errorf("Variant.Split() error = %v, want error for AC", err)
+	}
+}
+
+func Test_genotypeOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		ploidy int
+		want   [][]int
+	}{
+		{"haploid", 3, 1, [][]int{{0}, {1}, {2}}},
+		{"diploid", 3, 2, [][]int{{0, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}, {2, 2}}},
+		{"triploid", 2, 3, [][]int{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}, {1, 1, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := genotypeOrder(tt.n, tt.ploidy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("genotypeOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_remapGenotype(t *testing.T) {
+	tests := []struct {
+		gt        string
+		alleleMap []int
+		want      string
+	}{
+		{"1/2", []int{0, 2}, "0/1"},
+		{"2|1", []int{0, 1}, "0|1"},
+		{"./2", []int{0, 2}, "./1"},
+		{".", []int{0, 1}, "."},
+		{"3", []int{0, 3}, "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.gt, func(t *testing.T) {
+			if got := remapGenotype(tt.gt, tt.alleleMap); got != tt.want {
+				t.Errorf("remapGenotype() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
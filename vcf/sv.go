@@ -0,0 +1,263 @@
+package vcf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StructuralVariant describes the structural variation of a record, from
+// its symbolic or breakend ALT alleles and the SVTYPE, END, SVLEN, CIPOS and
+// CIEND INFO fields.
+type StructuralVariant struct {
+	// Type is SVTYPE or, if there is no SVTYPE, the type of the first
+	// symbolic ALT allele, for example, "DEL" for <DEL:ME>, or "BND" for a
+	// breakend.
+	Type string
+	// Start is the position of the base before the event, Pos.
+	Start int
+	// End is the last position of the event (see Variant.End).
+	End int
+	// Lengths holds the SVLEN of each ALT allele, as absolute values (VCF
+	// 4.2 gives deletions negative lengths). It is empty if there is no
+	// SVLEN.
+	Lengths []int
+	// CIPos and CIEnd are the confidence intervals around Start and End,
+	// relative to them, for example, [-10, 10]. They are zero if absent.
+	CIPos [2]int
+	CIEnd [2]int
+	// Breakends holds the breakend ALT alleles.
+	Breakends []Breakend
+}
+
+// Breakend is a breakend ALT allele (see section 5.4 of the VCF
+// specification). The four joins are:
+//
+//	t[p[  JoinedAfter, MateRight: the piece extending right of p follows t
+//	t]p]  JoinedAfter: the reverse complemented piece extending left of p follows t
+//	]p]t  the piece extending left of p precedes t
+//	[p[t  MateRight: the reverse complemented piece extending right of p precedes t
+//
+// Single breakends, for example, "G." and ".G", have no mate.
+type Breakend struct {
+	// Bases are the bases t, the REF base and any inserted sequence.
+	Bases string
+	// MateChrom and MatePos are the position p of the mate, or "" and 0
+	// for a single breakend. MateChrom may be an assembly contig in angle
+	// brackets, for example, "<ctg1>".
+	MateChrom string
+	MatePos   int
+	// JoinedAfter is true if the join is after Bases (t[p[, t]p] and t.)
+	// rather than before them.
+	JoinedAfter bool
+	// MateRight is true if the joined piece extends to the right of the
+	// mate position (t[p[ and [p[t).
+	MateRight bool
+}
+
+// IsSingle returns true if the breakend has no mate.
+func (b Breakend) IsSingle() bool {
+	return b.MateChrom == ""
+}
+
+func (b Breakend) String() string {
+	if b.IsSingle() {
+		if b.JoinedAfter {
+			return b.Bases + "."
+		}
+		return "." + b.Bases
+	}
+	bracket := "]"
+	if b.MateRight {
+		bracket = "["
+	}
+	mate := bracket + b.MateChrom + ":" + strconv.Itoa(b.MatePos) + bracket
+	if b.JoinedAfter {
+		return b.Bases + mate
+	}
+	return mate + b.Bases
+}
+
+var (
+	breakendRegexp       = regexp.MustCompile(`^([A-Za-z]*)([\[\]])(.+):([0-9]+)([\[\]])([A-Za-z]*)$`)
+	singleBreakendRegexp = regexp.MustCompile(`^(\.[A-Za-z]+|[A-Za-z]+\.)$`)
+)
+
+// ParseBreakend parses a breakend ALT allele.
+func ParseBreakend(a string) (Breakend, error) {
+	if singleBreakendRegexp.MatchString(a) {
+		if strings.HasSuffix(a, ".") {
+			return Breakend{Bases: a[:len(a)-1], JoinedAfter: true}, nil
+		}
+		return Breakend{Bases: a[1:]}, nil
+	}
+	m := breakendRegexp.FindStringSubmatch(a)
+	// The brackets must match, and there must be bases on exactly one side.
+	if m == nil || m[2] != m[5] || (m[1] == "") == (m[6] == "") {
+		return Breakend{}, fmt.Errorf("invalid breakend %q", a)
+	}
+	pos, err := strconv.Atoi(m[4])
+	if err != nil {
+		return Breakend{}, fmt.Errorf("invalid breakend %q", a)
+	}
+	return Breakend{
+		Bases:       m[1] + m[6],
+		MateChrom:   m[3],
+		MatePos:     pos,
+		JoinedAfter: m[1] != "",
+		MateRight:   m[2] == "[",
+	}, nil
+}
+
+// isBreakend returns true if a is a breakend allele.
+func isBreakend(a string) bool {
+	return strings.ContainsAny(a, "[]") || singleBreakendRegexp.MatchString(a)
+}
+
+// isSymbolicAllele returns true if a is a symbolic allele, a breakend or the
+// spanning deletion allele "*".
+func isSymbolicAllele(a string) bool {
+	return a == "*" || strings.HasPrefix(a, "<") || isBreakend(a)
+}
+
+// symbolicType returns the type of a symbolic allele, the part of its ID
+// before any ":", for example, "DUP" for <DUP:TANDEM>, "BND" for a breakend
+// and "" for other alleles.
+func symbolicType(a string) string {
+	switch {
+	case strings.HasPrefix(a, "<") && strings.HasSuffix(a, ">"):
+		return strings.SplitN(a[1:len(a)-1], ":", 2)[0]
+	case isBreakend(a):
+		return "BND"
+	}
+	return ""
+}
+
+// IsStructural returns true if any ALT allele is a symbolic allele, other
+// than <*> or <NON_REF>, or a breakend, or v has an SVTYPE INFO field.
+func (v Variant) IsStructural() bool {
+	if v.HasAttribute("SVTYPE") {
+		return true
+	}
+	for _, a := range v.Alt {
+		if t := symbolicType(a); t != "" && t != "*" && t != "NON_REF" {
+			return true
+		}
+	}
+	return false
+}
+
+// SVType returns SVTYPE or, if there is no SVTYPE, the type of the first
+// structural ALT allele (see StructuralVariant.Type), or "" if v is not a
+// structural variant.
+func (v Variant) SVType() string {
+	if t, ok := v.Info["SVTYPE"]; ok {
+		return t
+	}
+	for _, a := range v.Alt {
+		if t := symbolicType(a); t != "" && t != "*" && t != "NON_REF" {
+			return t
+		}
+	}
+	return ""
+}
+
+// StructuralVariant returns the structural variation of v. It returns an
+// error if v is not a structural variant (see IsStructural), or its
+// breakends or SV INFO fields are invalid.
+func (v Variant) StructuralVariant() (StructuralVariant, error) {
+	if !v.IsStructural() {
+		return StructuralVariant{}, fmt.Errorf("%s:%d is not a structural variant", v.Chrom, v.Pos)
+	}
+	sv := StructuralVariant{Type: v.SVType(), Start: v.Start(), End: v.End()}
+	if v.HasAttribute("END") {
+		if _, err := v.AttributeAsInt("END"); err != nil {
+			return StructuralVariant{}, v.infoError("END", err)
+		}
+	}
+	lengths, err := v.svLengths()
+	if err != nil {
+		return StructuralVariant{}, err
+	}
+	sv.Lengths = lengths
+	for _, k := range []string{"CIPOS", "CIEND"} {
+		if !v.HasAttribute(k) {
+			continue
+		}
+		xs, err := v.AttributeAsIntSlice(k)
+		if err != nil {
+			return StructuralVariant{}, err
+		}
+		if len(xs) != 2 || xs[0] == MissingInt || xs[1] == MissingInt {
+			return StructuralVariant{}, v.infoError(k, fmt.Errorf("expected two values, found %s", v.Info[k]))
+		}
+		if k == "CIPOS" {
+			sv.CIPos = [2]int{xs[0], xs[1]}
+		} else {
+			sv.CIEnd = [2]int{xs[0], xs[1]}
+		}
+	}
+	for _, a := range v.Alt {
+		if !isBreakend(a) {
+			continue
+		}
+		b, err := ParseBreakend(a)
+		if err != nil {
+			return StructuralVariant{}, fmt.Errorf("%s:%d: %w", v.Chrom, v.Pos, err)
+		}
+		sv.Breakends = append(sv.Breakends, b)
+	}
+	return sv, nil
+}
+
+// svLengths returns the absolute values of SVLEN, or nil if there is no
+// SVLEN.
+func (v Variant) svLengths() ([]int, error) {
+	if !v.HasAttribute("SVLEN") {
+		return nil, nil
+	}
+	xs, err := v.AttributeAsIntSlice("SVLEN")
+	if err != nil {
+		return nil, err
+	}
+	for i, x := range xs {
+		if x < 0 && x != MissingInt {
+			xs[i] = -x
+		}
+	}
+	return xs, nil
+}
+
+// spanningTypes are the symbolic allele types that cover SVLEN reference
+// bases after Pos.
+var spanningTypes = []string{"DEL", "DUP", "INV", "CNV"}
+
+// svLengthEnd returns the last position covered by the symbolic DEL, DUP,
+// INV and CNV alleles, according to SVLEN, or 0 if there are none.
+func (v Variant) svLengthEnd() int {
+	if !v.HasAttribute("SVLEN") {
+		return 0
+	}
+	lengths, err := v.svLengths()
+	if err != nil {
+		return 0
+	}
+	end := 0
+	for i, a := range v.Alt {
+		if !stringSliceContains(spanningTypes, symbolicType(a)) {
+			continue
+		}
+		// Before VCF 4.4 SVLEN may have a single value for every allele.
+		n := MissingInt
+		if i < len(lengths) {
+			n = lengths[i]
+		} else if len(lengths) == 1 {
+			n = lengths[0]
+		}
+		if n != MissingInt && v.Pos+n > end {
+			end = v.Pos + n
+		}
+	}
+	return end
+}
@@ -0,0 +1,125 @@
+package vcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+const svTestVCF = `##fileformat=VCFv4.2
+##ALT=<ID=DEL,Description="Deletion">
+##INFO=<ID=SVTYPE,Number=1,Type=String,Description="Type of structural variant">
+##INFO=<ID=END,Number=1,Type=Integer,Description="End position of the variant">
+##INFO=<ID=SVLEN,Number=.,Type=Integer,Description="Difference in length between REF and ALT alleles">
+##INFO=<ID=CIPOS,Number=2,Type=Integer,Description="Confidence interval around POS">
+##INFO=<ID=CIEND,Number=2,Type=Integer,Description="Confidence interval around END">
+##INFO=<ID=MATEID,Number=.,Type=String,Description="ID of mate breakends">
+##contig=<ID=1,length=249250621>
+##contig=<ID=2,length=243199373>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	100	del1	A	<DEL>	.	PASS	SVTYPE=DEL;END=300;SVLEN=-200;CIPOS=-10,10;CIEND=-5,5
+1	1000	del2	C	<DEL>	.	PASS	SVLEN=-500
+1	2000	dup1	G	<DUP:TANDEM>	.	PASS	SVLEN=100
+1	3000	ins1	T	<INS>	.	PASS	SVTYPE=INS;SVLEN=300
+1	4000	bnd1	G	G]2:500]	.	PASS	SVTYPE=BND;MATEID=bnd2
+2	500	bnd2	T	T]1:4000]	.	PASS	SVTYPE=BND;MATEID=bnd1
+2	600	bnd3	A	[1:5000[A	.	PASS	SVTYPE=BND
+2	700	bnd4	C	C.	.	PASS	SVTYPE=BND
+2	800	snp1	A	G	.	PASS	.
+2	900	bad1	A	A[1:10]	.	PASS	SVTYPE=BND
+`
+
+func TestVariant_StructuralVariant(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.vcf", svTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := make(map[string]Variant)
+	for _, x := range scanAll(t, s) {
+		vs[x.ID] = x
+	}
+	tests := []struct {
+		id      string
+		want    StructuralVariant
+		wantErr bool
+	}{
+		{"del1", StructuralVariant{Type: "DEL", Start: 100, End: 300, Lengths: []int{200}, CIPos: [2]int{-10, 10}, CIEnd: [2]int{-5, 5}}, false},
+		{"del2", StructuralVariant{Type: "DEL", Start: 1000, End: 1500, Lengths: []int{500}}, false},
+		{"dup1", StructuralVariant{Type: "DUP", Start: 2000, End: 2100, Lengths: []int{100}}, false},
+		{"ins1", StructuralVariant{Type: "INS", Start: 3000, End: 3000, Lengths: []int{300}}, false},
+		{"bnd1", StructuralVariant{Type: "BND", Start: 4000, End: 4000, Breakends: []Breakend{{Bases: "G", MateChrom: "2", MatePos: 500, JoinedAfter: true}}}, false},
+		{"bnd3", StructuralVariant{Type: "BND", Start: 600, End: 600, Breakends: []Breakend{{Bases: "A", MateChrom: "1", MatePos: 5000, MateRight: true}}}, false},
+		{"bnd4", StructuralVariant{Type: "BND", Start: 700, End: 700, Breakends: []Breakend{{Bases: "C", JoinedAfter: true}}}, false},
+		{"snp1", StructuralVariant{}, true},
+		{"bad1", StructuralVariant{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := vs[tt.id].StructuralVariant()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Variant.StructuralVariant() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variant.StructuralVariant() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBreakend(t *testing.T) {
+	tests := []struct {
+		allele  string
+		want    Breakend
+		wantErr bool
+	}{
+		{"G]17:198982]", Breakend{Bases: "G", MateChrom: "17", MatePos: 198982, JoinedAfter: true}, false},
+		{"G[17:198982[", Breakend{Bases: "G", MateChrom: "17", MatePos: 198982, JoinedAfter: true, MateRight: true}, false},
+		{"]13:123456]T", Breakend{Bases: "T", MateChrom: "13", MatePos: 123456}, false},
+		{"[13:123456[T", Breakend{Bases: "T", MateChrom: "13", MatePos: 123456, MateRight: true}, false},
+		{"CAGTNNNNNCA[2:321682[", Breakend{Bases: "CAGTNNNNNCA", MateChrom: "2", MatePos: 321682, JoinedAfter: true, MateRight: true}, false},
+		{"C[<ctg1>:7[", Breakend{Bases: "C", MateChrom: "<ctg1>", MatePos: 7, JoinedAfter: true, MateRight: true}, false},
+		{"G[HLA-A*01:01:01:01:100[", Breakend{Bases: "G", MateChrom: "HLA-A*01:01:01:01", MatePos: 100, JoinedAfter: true, MateRight: true}, false},
+		{".A", Breakend{Bases: "A"}, false},
+		{"A.", Breakend{Bases: "A", JoinedAfter: true}, false},
+		{"G]17:198982[", Breakend{}, true},
+		{"G]17:198982]T", Breakend{}, true},
+		{"]17:198982]", Breakend{}, true},
+		{"G]17]", Breakend{}, true},
+		{"<DEL>", Breakend{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.allele, func(t *testing.T) {
+			got, err := ParseBreakend(tt.allele)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBreakend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBreakend() = %+v, want %+v", got, tt.want)
+			}
+			if err == nil && got.String() != tt.allele {
+				t.Errorf("Breakend.String() = %s, want %s", got, tt.allele)
+			}
+		})
+	}
+}
+
+func TestRegion_Overlaps_sv(t *testing.T) {
+	v, err := New(writeTestFile(t, "test.vcf", svTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v, "1:1400-1600", "1:2050")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, x := range scanAll(t, s) {
+		got = append(got, x.ID)
+	}
+	if want := []string{"del2", "dup1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Scanner variants = %v, want %v", got, want)
+	}
+}
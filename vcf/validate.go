@@ -0,0 +1,442 @@
+package vcf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity is the severity of a Finding.
+type Severity int
+
+const (
+	// SeverityWarning is a finding that is allowed by the specification
+	// but is likely to cause problems, for example, a duplicate ID.
+	SeverityWarning Severity = iota
+	// SeverityError is a violation of the specification.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Finding is a problem found by a Validator.
+type Finding struct {
+	// Line is the line number of the header line or record in a VCF, or
+	// the number of the record in a BCF, counting from 1.
+	Line     int
+	Severity Severity
+	// Chrom and Pos locate the record, they are empty for the header.
+	Chrom   string
+	Pos     int
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Chrom == "" {
+		return fmt.Sprintf("line %d: %s: %s", f.Line, f.Severity, f.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s:%d: %s", f.Line, f.Severity, f.Chrom, f.Pos, f.Message)
+}
+
+var (
+	basesRegexp    = regexp.MustCompile(`^[ACGTNacgtn]+$`)
+	symbolicRegexp = regexp.MustCompile(`^<([^<>]+)>$`)
+	numberRegexp   = regexp.MustCompile(`^([0-9]+|A|R|G|P|LA|LR|LG|\.)$`)
+)
+
+// standardAlts are the symbolic alleles defined by the specification, which
+// do not need ALT header lines.
+var standardAlts = []string{"DEL", "INS", "DUP", "INV", "CNV", "DUP:TANDEM", "DEL:ME", "INS:ME", "NON_REF", "*"}
+
+// Validator checks variants against the VCF specification and a header.
+// Unlike Writer.WriteVariant it does not stop at the first problem, every
+// problem with a variant is reported as a Finding.
+type Validator struct {
+	header Header
+	ref    Reference
+	last   *Variant
+	// done holds the contigs before the contig of last.
+	done map[string]bool
+	ids  map[string]int
+}
+
+// NewValidator creates a Validator for variants with the header h. If ref is
+// not nil the REF alleles are checked against it.
+func NewValidator(h Header, ref Reference) *Validator {
+	return &Validator{header: h, ref: ref, done: make(map[string]bool), ids: make(map[string]int)}
+}
+
+// Validate checks the header and every record of v (see Validator). The
+// findings are returned in file order. An error is only returned if the
+// file can not be read.
+func Validate(v VCF, ref Reference) ([]Finding, error) {
+	f, err := openFile(v.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	val := NewValidator(v.Header, ref)
+	findings := val.CheckHeader()
+	if f.isBCF() {
+		if _, err := readBCFHeader(f); err != nil {
+			return nil, err
+		}
+		r := newBCFReader(f, newBCFDict(v.Header), v.Header.Samples)
+		for n := 1; ; n++ {
+			x, err := r.read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return findings, err
+			}
+			findings = append(findings, val.Check(x, n)...)
+		}
+		return findings, nil
+	}
+	// Findings report the physical line number, so the header is read
+	// again, noting the line of each header line.
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 100000), maxLineSize)
+	nHeader := len(findings)
+	var headerLines []int
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		if strings.HasPrefix(line, metadataIndicator) {
+			headerLines = append(headerLines, n)
+			continue
+		}
+		findings = append(findings, val.checkLine(line, n)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return findings, fmt.Errorf("unable to read %s: %w", v.file, err)
+	}
+	// CheckHeader numbers the header lines from 1.
+	for i, f := range findings[:nHeader] {
+		if f.Line > 0 && f.Line <= len(headerLines) {
+			findings[i].Line = headerLines[f.Line-1]
+		}
+	}
+	return findings, nil
+}
+
+// CheckHeader checks the header for duplicate and invalid definitions.
+func (val *Validator) CheckHeader() []Finding {
+	var xs []Finding
+	add := func(i int, severity Severity, format string, args ...interface{}) {
+		xs = append(xs, Finding{Line: i + 1, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+	if val.header.version < 4.0 || val.header.version > 4.4 {
+		add(0, SeverityWarning, "unsupported VCF version %.1f", val.header.version)
+	}
+	seen := make(map[string]int)
+	for i, l := range val.header.lines {
+		if l.ID() == "" {
+			continue
+		}
+		key := l.Key + "/" + l.ID()
+		if j, ok := seen[key]; ok {
+			add(i, SeverityError, "%s %s is already defined on line %d", l.Key, l.ID(), j+1)
+			continue
+		}
+		seen[key] = i
+		if l.Key != "INFO" && l.Key != "FORMAT" {
+			continue
+		}
+		typ, number := l.Get("Type"), l.Get("Number")
+		switch typ {
+		case "Integer", "Float", "Character", "String":
+		case "Flag":
+			if l.Key == "FORMAT" {
+				add(i, SeverityError, "FORMAT %s has Type=Flag", l.ID())
+			} else if number != "0" {
+				add(i, SeverityError, "INFO %s has Type=Flag but Number=%s", l.ID(), number)
+			}
+		default:
+			add(i, SeverityError, "%s %s has invalid Type=%s", l.Key, l.ID(), typ)
+		}
+		if !numberRegexp.MatchString(number) {
+			add(i, SeverityError, "%s %s has invalid Number=%s", l.Key, l.ID(), number)
+		}
+	}
+	return xs
+}
+
+// checkLine parses and checks a line of a VCF. Columns that would prevent
+// the line being parsed are reported instead of checking the variant.
+func (val *Validator) checkLine(line string, n int) []Finding {
+	if line == "" || strings.HasPrefix(line, headerIndicator) {
+		return nil
+	}
+	cols := strings.Split(line, "\t")
+	finding := func(format string, args ...interface{}) []Finding {
+		f := Finding{Line: n, Severity: SeverityError, Message: fmt.Sprintf(format, args...)}
+		if len(cols) > 1 {
+			f.Chrom = cols[0]
+			f.Pos, _ = strconv.Atoi(cols[1])
+		}
+		return []Finding{f}
+	}
+	samples := val.header.Samples
+	switch {
+	case len(cols) < 8:
+		return finding("expected at least 8 columns, found %d", len(cols))
+	case len(samples) == 0 && len(cols) > 8 && len(cols) != 9:
+		return finding("expected 8 columns for a VCF without samples, found %d", len(cols))
+	case len(samples) > 0 && len(cols) != 9+len(samples):
+		return finding("expected %d columns for %d samples, found %d", 9+len(samples), len(samples), len(cols))
+	}
+	if len(cols) > 9 {
+		nFormat := strings.Count(cols[8], ":") + 1
+		for i, s := range cols[9:] {
+			m := strings.Count(s, ":") + 1
+			if m > nFormat {
+				return finding("sample %s has %d fields, but FORMAT has %d", samples[i], m, nFormat)
+			}
+		}
+	}
+	v, err := parseVcfColumns(cols, samples)
+	if err != nil {
+		return finding("%v", err)
+	}
+	return val.Check(v, n)
+}
+
+// Check checks a variant, returning its findings with the given line number.
+func (val *Validator) Check(v Variant, line int) []Finding {
+	v.header = &val.header
+	var xs []Finding
+	add := func(severity Severity, format string, args ...interface{}) {
+		xs = append(xs, Finding{Line: line, Severity: severity, Chrom: v.Chrom, Pos: v.Pos, Message: fmt.Sprintf(format, args...)})
+	}
+	val.checkPosition(v, add)
+	val.checkAlleles(v, add)
+	if v.Qual != "." && v.Qual != "" {
+		if q, err := strconv.ParseFloat(v.Qual, 64); err != nil || q < 0 {
+			add(SeverityError, "invalid QUAL %s", v.Qual)
+		}
+	}
+	for _, f := range v.Filter {
+		if !hasID(val.header.Filters(), f) {
+			add(SeverityError, "FILTER %s is not defined in the header", f)
+		}
+	}
+	for _, id := range strings.Split(v.ID, ";") {
+		if id == "." || id == "" {
+			continue
+		}
+		if j, ok := val.ids[id]; ok {
+			add(SeverityWarning, "duplicate ID %s, also on line %d", id, j)
+			continue
+		}
+		val.ids[id] = line
+	}
+	val.checkInfo(v, add)
+	val.checkFormat(v, add)
+	return xs
+}
+
+type addFinding func(severity Severity, format string, args ...interface{})
+
+// checkPosition checks the contig and position of v, and that it is sorted.
+func (val *Validator) checkPosition(v Variant, add addFinding) {
+	if contigs := val.header.Contigs(); len(contigs) > 0 {
+		found := false
+		for _, c := range contigs {
+			if c.ID() != v.Chrom {
+				continue
+			}
+			found = true
+			if n, err := strconv.Atoi(c.Get("length")); err == nil && v.Pos > n {
+				add(SeverityError, "position is after the end of contig %s (length %d)", v.Chrom, n)
+			}
+		}
+		if !found {
+			add(SeverityError, "contig %s is not defined in the header", v.Chrom)
+		}
+	}
+	if v.Pos < 0 {
+		add(SeverityError, "invalid position %d", v.Pos)
+	}
+	if val.last != nil {
+		if v.Chrom != val.last.Chrom {
+			val.done[val.last.Chrom] = true
+			if val.done[v.Chrom] {
+				add(SeverityError, "not sorted: the records of contig %s are not contiguous", v.Chrom)
+			}
+		} else if v.Pos < val.last.Pos {
+			add(SeverityError, "not sorted: after %s:%d", val.last.Chrom, val.last.Pos)
+		}
+	}
+	val.last = &v
+}
+
+// checkAlleles checks the REF and ALT alleles of v, and checks REF against
+// the reference if there is one.
+func (val *Validator) checkAlleles(v Variant, add addFinding) {
+	if !basesRegexp.MatchString(v.Ref) {
+		add(SeverityError, "invalid REF allele %s", v.Ref)
+	}
+	for _, a := range v.Alt {
+		switch {
+		case a == "." && len(v.Alt) == 1, a == "*":
+		case symbolicRegexp.MatchString(a):
+			id := symbolicRegexp.FindStringSubmatch(a)[1]
+			if !stringSliceContains(standardAlts, id) && !hasID(val.header.Alts(), id) {
+				add(SeverityWarning, "symbolic allele %s is not defined in the header", a)
+			}
+		case strings.ContainsAny(a, "[]"), strings.HasPrefix(a, "."), strings.HasSuffix(a, "."):
+			// A breakend.
+		case !basesRegexp.MatchString(a):
+			add(SeverityError, "invalid ALT allele %s", a)
+		case strings.EqualFold(a, v.Ref):
+			add(SeverityError, "ALT allele %s is the same as REF", a)
+		}
+	}
+	if val.ref == nil || !basesRegexp.MatchString(v.Ref) {
+		return
+	}
+	seq, err := val.ref.Query(v.Chrom, v.Pos-1, v.Pos-1+len(v.Ref))
+	if err != nil {
+		add(SeverityWarning, "unable to check REF against the reference: %v", err)
+		return
+	}
+	if !strings.EqualFold(seq, v.Ref) {
+		add(SeverityError, "REF %s does not match the reference %s", v.Ref, seq)
+	}
+}
+
+// checkInfo checks the INFO fields of v against the header, and that END is
+// consistent with the position and REF.
+func (val *Validator) checkInfo(v Variant, add addFinding) {
+	keys := make([]string, 0, len(v.Info))
+	for k := range v.Info {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		def := v.definition("INFO", k)
+		if def == nil {
+			add(SeverityError, "INFO %s is not defined in the header", k)
+			continue
+		}
+		if def.Get("Type") == "Flag" {
+			// Flags are parsed with the value 1.
+			if v.Info[k] != "1" || stringSliceContains(v.explicitOnes, k) {
+				add(SeverityError, "INFO %s is a Flag but has a value", k)
+			}
+			continue
+		}
+		xs, _, err := v.infoValues(k)
+		if err != nil {
+			add(SeverityError, "INFO %s: %v", k, unwrapFieldError(err))
+			continue
+		}
+		if err := checkValueTypes(def, xs); err != nil {
+			add(SeverityError, "INFO %s: %v", k, err)
+		}
+	}
+	end, ok := v.Info["END"]
+	if !ok {
+		return
+	}
+	e, err := strconv.Atoi(end)
+	if err != nil {
+		return
+	}
+	if e < v.Pos {
+		add(SeverityError, "END %d is before the position", e)
+		return
+	}
+	for _, a := range v.Alt {
+		if !basesRegexp.MatchString(a) {
+			return
+		}
+	}
+	if e != v.Pos+len(v.Ref)-1 {
+		add(SeverityWarning, "END %d does not match the length of REF", e)
+	}
+}
+
+// checkFormat checks the FORMAT fields of each genotype of v against the
+// header and that the GT allele indexes are in range.
+func (val *Validator) checkFormat(v Variant, add addFinding) {
+	for i, k := range v.Format {
+		if v.definition("FORMAT", k) == nil {
+			add(SeverityError, "FORMAT %s is not defined in the header", k)
+		}
+		if k == "GT" && i > 0 {
+			add(SeverityError, "GT is not the first FORMAT field")
+		}
+	}
+	nAlleles := v.nAlt() + 1
+	for _, g := range v.Genotypes() {
+		for _, k := range v.Format {
+			def := v.definition("FORMAT", k)
+			if def == nil {
+				continue
+			}
+			if k == "GT" {
+				for _, i := range g.alleleIndexes {
+					if i >= nAlleles {
+						add(SeverityError, "FORMAT GT of %s: invalid allele %d", g.Name, i)
+					}
+				}
+				continue
+			}
+			xs, _, err := g.formatValues(k)
+			if err != nil {
+				add(SeverityError, "FORMAT %s of %s: %v", k, g.Name, unwrapFieldError(err))
+				continue
+			}
+			if err := checkValueTypes(def, xs); err != nil {
+				add(SeverityError, "FORMAT %s of %s: %v", k, g.Name, err)
+			}
+		}
+	}
+}
+
+// checkValueTypes checks that the values of a field have the type declared
+// by def.
+func checkValueTypes(def *HeaderLine, xs []string) error {
+	var err error
+	switch def.Get("Type") {
+	case "Integer":
+		_, err = parseInts(xs)
+	case "Float":
+		_, err = parseFloats(xs)
+	case "Character":
+		for _, x := range xs {
+			if len(x) != 1 {
+				return fmt.Errorf("invalid Character value %q", x)
+			}
+		}
+	}
+	return err
+}
+
+// unwrapFieldError returns the cause of an error from infoValues or
+// formatValues, without the position and field already in the finding.
+func unwrapFieldError(err error) error {
+	for {
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok || u.Unwrap() == nil {
+			return err
+		}
+		err = u.Unwrap()
+	}
+}
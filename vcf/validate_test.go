@@ -0,0 +1,129 @@
+package vcf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const validateTestVCF = `##fileformat=VCFv4.2
+##FILTER=<ID=q10,Description="Quality below 10">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP">
+##INFO=<ID=END,Number=1,Type=Integer,Description="End position">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Duplicate">
+##INFO=<ID=BAD,Number=X,Type=Text,Description="Invalid">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##contig=<ID=1,length=20>
+##contig=<ID=2,length=20>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	1	rs1	T	C	50	PASS	DP=10;AC=1;DB	GT:AD	0/1:5,5	0/0:10,0
+1	3	rs1	G	C,T	x	q20	DP=1.5;AC=1;XX=1;DB=1	GT:AD	0/3:5,5	1/2
+1	2	.	CA	C	.	PASS	END=5	AD:GT	1,2,3:0/1	.:./.
+2	5	.	A	A,<FOO>	.	PASS	.	GT	0/1	0/1
+1	30	.	AZ	C	.	PASS	.	GT	0/1	0/1
+1	31	.	A	C	.	PASS	.	GT	0/1
+1	32	.	A	C	.	PASS	.	GT	0/1:3	0/1
+`
+
+func TestValidate(t *testing.T) {
+	v, err := New(writeTestFile(t, "invalid.vcf", validateTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := testReference{"1": "TCACACAGGGTCACACAGGG", "2": "TCACACAGGGTCACACAGGG"}
+	findings, err := Validate(v, ref)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	got := []string{}
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"line 7: error: INFO DP is already defined on line 3",
+		"line 8: error: INFO BAD has invalid Type=Text",
+		"line 8: error: INFO BAD has invalid Number=X",
+		"line 15: error: 1:3: REF G does not match the reference A",
+		"line 15: error: 1:3: invalid QUAL x",
+		"line 15: error: 1:3: FILTER q20 is not defined in the header",
+		"line 15: warning: 1:3: duplicate ID rs1, also on line 14",
+		"line 15: error: 1:3: INFO AC: expected 2 values for Number=A, found 1",
+		"line 15: error: 1:3: INFO DB is a Flag but has a value",
+		"line 15: error: 1:3: INFO DP: invalid Integer value \"1.5\"",
+		"line 15: error: 1:3: INFO XX is not defined in the header",
+		"line 15: error: 1:3: FORMAT GT of S1: invalid allele 3",
+		"line 15: error: 1:3: FORMAT AD of S1: expected 3 values for Number=R, found 2",
+		"line 16: error: 1:2: not sorted: after 1:3",
+		"line 16: warning: 1:2: END 5 does not match the length of REF",
+		"line 16: error: 1:2: GT is not the first FORMAT field",
+		"line 16: error: 1:2: FORMAT AD of S1: expected 2 values for Number=R, found 3",
+		"line 17: error: 2:5: ALT allele A is the same as REF",
+		"line 17: warning: 2:5: symbolic allele <FOO> is not defined in the header",
+		"line 18: error: 1:30: position is after the end of contig 1 (length 20)",
+		"line 18: error: 1:30: not sorted: the records of contig 1 are not contiguous",
+		"line 18: error: 1:30: invalid REF allele AZ",
+		"line 19: error: 1:31: expected 11 columns for 2 samples, found 10",
+		"line 20: error: 1:32: sample S1 has 2 fields, but FORMAT has 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidate_lineNumbers(t *testing.T) {
+	const vcf = `##fileformat=VCFv4.2
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+#a header comment
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+
+1	1	.	A	C	.	.	DP=x
+#a comment
+1	2	.	A	C	.	.	XX=1
+`
+	v, err := New(writeTestFile(t, "test.vcf", vcf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := Validate(v, nil)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	got := []string{}
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"line 4: error: INFO DP is already defined on line 2",
+		"line 7: error: 1:1: INFO DP: invalid Integer value \"x\"",
+		"line 9: error: 1:2: INFO XX is not defined in the header",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidate_valid(t *testing.T) {
+	for _, name := range []string{"valid.vcf", "valid.bcf"} {
+		t.Run(name, func(t *testing.T) {
+			path := writeTestFile(t, name, testVCF)
+			if name == "valid.bcf" {
+				path = writeBGZF(t, name, bcfTestFile())
+			}
+			v, err := New(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			findings, err := Validate(v, nil)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if len(findings) != 0 {
+				t.Errorf("Validate() findings = %v, want none", findings)
+			}
+		})
+	}
+}
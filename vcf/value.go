@@ -0,0 +1,312 @@
+package vcf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MissingInt is returned by the integer slice accessors for missing (".")
+// values.
+const MissingInt = math.MinInt32
+
+// IsMissingFloat returns true if f is the value returned by the float slice
+// accessors for missing (".") values, which is NaN.
+func IsMissingFloat(f float64) bool {
+	return math.IsNaN(f)
+}
+
+// definition returns the INFO or FORMAT header line (as given by key)
+// defining id.
+func (h Header) definition(key, id string) (HeaderLine, bool) {
+	for _, l := range h.lines {
+		if l.Key == key && l.ID() == id {
+			return l, true
+		}
+	}
+	return HeaderLine{}, false
+}
+
+// definition returns the header definition of an INFO or FORMAT field, or
+// nil if v has no header or the header does not define it.
+func (v Variant) definition(key, id string) *HeaderLine {
+	if v.header == nil {
+		return nil
+	}
+	if l, ok := v.header.definition(key, id); ok {
+		return &l
+	}
+	return nil
+}
+
+// expectedValues returns the number of values a field declared with number
+// should have at a site with nAlt alternate alleles, or -1 if any number is
+// allowed. Number=G and P depend on the ploidy of the sample. For the local
+// allele numbers (LA, LR and LG) nAlt is the number of local alternate
+// alleles.
+func expectedValues(number string, nAlt, ploidy int) int {
+	switch number {
+	case "A", "LA":
+		return nAlt
+	case "R", "LR":
+		return nAlt + 1
+	case "G", "LG":
+		return numGenotypes(nAlt+1, ploidy)
+	case "P":
+		return ploidy
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// numGenotypes returns the number of unordered genotypes of the given
+// ploidy that can be made from n alleles.
+func numGenotypes(n, ploidy int) int {
+	// The binomial coefficient (n+ploidy-1 choose ploidy).
+	x := 1
+	for i := 1; i <= ploidy; i++ {
+		x = x * (n + i - 1) / i
+	}
+	return x
+}
+
+// splitValues splits a comma separated value, checking the number of
+// elements against the header definition def if there is one. A single
+// "." (the whole value is missing) is always allowed.
+func splitValues(def *HeaderLine, value string, nAlt, ploidy int) ([]string, error) {
+	xs := strings.Split(value, ",")
+	if def == nil || value == "." {
+		return xs, nil
+	}
+	number := def.Get("Number")
+	if n := expectedValues(number, nAlt, ploidy); n >= 0 && len(xs) != n {
+		return nil, fmt.Errorf("expected %d values for Number=%s, found %d", n, number, len(xs))
+	}
+	return xs, nil
+}
+
+// checkType returns an error if the header definition def declares a type
+// other than one of types.
+func checkType(def *HeaderLine, types ...string) error {
+	if def == nil {
+		return nil
+	}
+	typ := def.Get("Type")
+	for _, t := range types {
+		if typ == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("declared as Type=%s", typ)
+}
+
+func parseInts(xs []string) ([]int, error) {
+	ys := make([]int, len(xs))
+	for i, x := range xs {
+		if x == "." {
+			ys[i] = MissingInt
+			continue
+		}
+		y, err := strconv.Atoi(x)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Integer value %q", x)
+		}
+		ys[i] = y
+	}
+	return ys, nil
+}
+
+func parseFloats(xs []string) ([]float64, error) {
+	ys := make([]float64, len(xs))
+	for i, x := range xs {
+		if x == "." {
+			ys[i] = math.NaN()
+			continue
+		}
+		y, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Float value %q", x)
+		}
+		ys[i] = y
+	}
+	return ys, nil
+}
+
+// nAlt returns the number of alternate alleles, not counting the "." used
+// when there are none.
+func (v Variant) nAlt() int {
+	if len(v.Alt) == 1 && v.Alt[0] == "." {
+		return 0
+	}
+	return len(v.Alt)
+}
+
+// infoValues returns the elements of the INFO field key and its header
+// definition, if any.
+func (v Variant) infoValues(key string) ([]string, *HeaderLine, error) {
+	value, ok := v.Info[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("no such info: %s", key)
+	}
+	def := v.definition("INFO", key)
+	// Number=G in INFO fields assumes diploid samples.
+	xs, err := splitValues(def, value, v.nAlt(), 2)
+	if err != nil {
+		return nil, nil, v.infoError(key, err)
+	}
+	return xs, def, nil
+}
+
+func (v Variant) infoError(key string, err error) error {
+	return fmt.Errorf("%s:%d: INFO %s: %w", v.Chrom, v.Pos, key, err)
+}
+
+// formatValues returns the elements of the FORMAT field key of g and its
+// header definition, if any.
+func (g Genotype) formatValues(key string) ([]string, *HeaderLine, error) {
+	value, ok := g.values[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("no such attribute: %s", key)
+	}
+	if g.v == nil {
+		return strings.Split(value, ","), nil, nil
+	}
+	def := g.v.definition("FORMAT", key)
+	nAlt := g.v.nAlt()
+	if def != nil && isLocalNumber(def.Get("Number")) {
+		laa, err := g.LocalAlleles()
+		if err != nil {
+			return nil, nil, err
+		}
+		nAlt = len(laa) - 1
+	}
+	xs, err := splitValues(def, value, nAlt, g.fieldPloidy(def, value, nAlt))
+	if err != nil {
+		return nil, nil, g.formatError(key, err)
+	}
+	return xs, def, nil
+}
+
+func (g Genotype) formatError(key string, err error) error {
+	if g.v == nil {
+		return fmt.Errorf("FORMAT %s of %s: %w", key, g.Name, err)
+	}
+	return fmt.Errorf("%s:%d: FORMAT %s of %s: %w", g.v.Chrom, g.v.Pos, key, g.Name, err)
+}
+
+func isLocalNumber(number string) bool {
+	return number == "LA" || number == "LR" || number == "LG"
+}
+
+// gtPloidy returns the number of alleles in the GT field, including missing
+// alleles. A GT of "." does not give the ploidy, so the ploidy of the first
+// other sample with a GT is used; it is assumed diploid if there is none,
+// or if there is no GT field.
+func (g Genotype) gtPloidy() int {
+	gt, ok := g.values["GT"]
+	if !ok {
+		return 2
+	}
+	if gt != "." {
+		return g.Ploidy()
+	}
+	if g.v != nil {
+		for _, o := range g.v.genotypes {
+			if x, ok := o.values["GT"]; ok && x != "." {
+				return o.Ploidy()
+			}
+		}
+	}
+	return 2
+}
+
+// maxInferredPloidy is the largest ploidy fieldPloidy infers from the number
+// of values of a Number=G field.
+const maxInferredPloidy = 8
+
+// fieldPloidy returns the ploidy of g for the FORMAT field with the
+// definition def and value. For a GT of "." and a Number=G field it is the
+// ploidy that gives the number of values, if there is one, so that, for
+// example, "PL=.,.,." of a diploid no-call is accepted; otherwise it is
+// gtPloidy.
+func (g Genotype) fieldPloidy(def *HeaderLine, value string, nAlt int) int {
+	if def == nil || def.Get("Number") != "G" || g.values["GT"] != "." || value == "." {
+		return g.gtPloidy()
+	}
+	n := strings.Count(value, ",") + 1
+	for p := 1; p <= maxInferredPloidy; p++ {
+		if numGenotypes(nAlt+1, p) == n {
+			return p
+		}
+	}
+	return g.gtPloidy()
+}
+
+// percentEncoder encodes the characters that VCF 4.3 requires to be
+// percent-encoded in INFO and FORMAT values.
+var percentEncoder = strings.NewReplacer(
+	"%", "%25", ":", "%3A", ";", "%3B", "=", "%3D", ",", "%2C",
+	"\r", "%0D", "\n", "%0A", "\t", "%09",
+)
+
+// PercentEncode encodes the characters with special meanings in INFO and
+// FORMAT values (":;=%," and CR, LF and tab) as required by VCF 4.3, for
+// example, "a;b" becomes "a%3Bb". Each element of a list must be encoded
+// separately.
+func PercentEncode(s string) string {
+	return percentEncoder.Replace(s)
+}
+
+// infoEscaper and formatEscaper percent-encode the characters that would
+// end an INFO or FORMAT value when it is written. Commas separate the
+// elements of a list and "%" may start an encoding already, so they are
+// written as they are; PercentEncode encodes single elements fully.
+var (
+	infoEscaper   = strings.NewReplacer(";", "%3B", "\t", "%09", "\r", "%0D", "\n", "%0A")
+	formatEscaper = strings.NewReplacer(":", "%3A", "\t", "%09", "\r", "%0D", "\n", "%0A")
+)
+
+// PercentDecode decodes the percent-encoded characters in s.
+func PercentDecode(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid percent encoding in %q", s)
+		}
+		x, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid percent encoding in %q", s)
+		}
+		b.WriteByte(byte(x))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// decodeValues percent-decodes the elements of a value if the header is
+// VCF 4.3 or later.
+func decodeValues(h *Header, xs []string) ([]string, error) {
+	if h == nil || h.version < 4.3 {
+		return xs, nil
+	}
+	ys := make([]string, len(xs))
+	for i, x := range xs {
+		y, err := PercentDecode(x)
+		if err != nil {
+			return nil, err
+		}
+		ys[i] = y
+	}
+	return ys, nil
+}
@@ -0,0 +1,124 @@
+package vcf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_numGenotypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		alleles int
+		ploidy  int
+		want    int
+	}{
+		{"haploid biallelic", 2, 1, 2},
+		{"diploid biallelic", 2, 2, 3},
+		{"diploid triallelic", 3, 2, 6},
+		{"triploid biallelic", 2, 3, 4},
+		{"diploid monomorphic", 1, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := numGenotypes(tt.alleles, tt.ploidy); got != tt.want {
+				t.Errorf("numGenotypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentEncode(t *testing.T) {
+	tests := []struct {
+		decoded string
+		encoded string
+	}{
+		{"plain", "plain"},
+		{"a;b=c", "a%3Bb%3Dc"},
+		{"50%,1:2", "50%25%2C1%3A2"},
+		{"tab\there\r\n", "tab%09here%0D%0A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.decoded, func(t *testing.T) {
+			if got := PercentEncode(tt.decoded); got != tt.encoded {
+				t.Errorf("PercentEncode() = %v, want %v", got, tt.encoded)
+			}
+			got, err := PercentDecode(tt.encoded)
+			if err != nil || got != tt.decoded {
+				t.Errorf("PercentDecode() = %v, %v, want %v", got, err, tt.decoded)
+			}
+		})
+	}
+	for _, s := range []string{"50%", "%2", "%zz"} {
+		if _, err := PercentDecode(s); err == nil {
+			t.Errorf("PercentDecode(%q) expected error", s)
+		}
+	}
+}
+
+const localTestVCF = `##fileformat=VCFv4.4
+##INFO=<ID=NOTE,Number=.,Type=String,Description="Free text">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=PS,Number=P,Type=Integer,Description="Per-allele phase sets">
+##FORMAT=<ID=LAA,Number=.,Type=Integer,Description="Local alternate alleles">
+##FORMAT=<ID=LAD,Number=LR,Type=Integer,Description="Local allelic depths">
+##FORMAT=<ID=LPL,Number=LG,Type=Integer,Description="Local phred-scaled genotype likelihoods">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	.	A	C,G,T	.	PASS	NOTE=a%3Bb,c%2Cd	GT:PS:LAA:LAD:LPL	0/2:1,1:2:5,6:0,10,20	1/3:.,.:1,3:1,2,3:0,1,2,3,4,5
+`
+
+func TestGenotype_GlobalValues(t *testing.T) {
+	s, err := NewReader(strings.NewReader(localTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := scanAll(t, s)[0]
+	note, err := v.AttributeAsStringSlice("NOTE")
+	if err != nil || !reflect.DeepEqual(note, []string{"a;b", "c,d"}) {
+		t.Errorf("Variant.AttributeAsStringSlice() = %q, %v, want percent-decoded values", note, err)
+	}
+	tests := []struct {
+		sample string
+		local  []int
+		ps     []int
+		ad     []string
+		pl     []string
+	}{
+		{"S1", []int{0, 2}, []int{1, 1}, []string{"5", ".", "6", "."}, []string{"0", ".", ".", "10", ".", "20", ".", ".", ".", "."}},
+		{"S2", []int{0, 1, 3}, []int{MissingInt, MissingInt}, []string{"1", "2", ".", "3"}, []string{"0", "1", "2", ".", ".", ".", "3", "4", ".", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			g, err := v.Sample(tt.sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := g.LocalAlleles(); err != nil || !reflect.DeepEqual(got, tt.local) {
+				t.Errorf("Genotype.LocalAlleles() = %v, %v, want %v", got, err, tt.local)
+			}
+			if got, err := g.AttributeAsIntSlice("PS"); err != nil || !reflect.DeepEqual(got, tt.ps) {
+				t.Errorf("Genotype.AttributeAsIntSlice(PS) = %v, %v, want %v", got, err, tt.ps)
+			}
+			if got, err := g.GlobalValues("LAD"); err != nil || !reflect.DeepEqual(got, tt.ad) {
+				t.Errorf("Genotype.GlobalValues(LAD) = %v, %v, want %v", got, err, tt.ad)
+			}
+			if got, err := g.GlobalValues("LPL"); err != nil || !reflect.DeepEqual(got, tt.pl) {
+				t.Errorf("Genotype.GlobalValues(LPL) = %v, %v, want %v", got, err, tt.pl)
+			}
+			if _, err := g.GlobalValues("PS"); err == nil {
+				t.Error("Genotype.GlobalValues(PS) expected error")
+			}
+		})
+	}
+	s, err = NewReader(strings.NewReader(strings.Replace(localTestVCF, "0/2:1,1:2:5,6", "0/2:1:2:5,6,7", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, _ := scanAll(t, s)[0].Sample("S1")
+	if _, err := g.AttributeAsIntSlice("PS"); err == nil {
+		t.Error("Genotype.AttributeAsIntSlice(PS) expected error for wrong ploidy")
+	}
+	if _, err := g.GlobalValues("LAD"); err == nil {
+		t.Error("Genotype.GlobalValues(LAD) expected error for wrong number of local alleles")
+	}
+}
@@ -1,8 +1,8 @@
 package vcf
 
 import (
-	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -19,11 +19,18 @@ type Variant struct {
 	Format    []string
 	genotypes []Genotype
 	header    *Header
+	// infoKeys are the INFO keys in the order they were read, so records
+	// are written with their INFO fields in the same order.
+	infoKeys []string
+	// explicitOnes are the INFO keys read with the value 1, which flags are
+	// also given, so that a Flag with a value can be told apart.
+	explicitOnes []string
 }
 
 func (v Variant) Sample(name string) (Genotype, error) {
 	for _, g := range v.genotypes {
 		if g.Name == name {
+			g.v = &v
 			return g, nil
 		}
 	}
@@ -31,7 +38,14 @@ func (v Variant) Sample(name string) (Genotype, error) {
 }
 
 func (v Variant) Genotypes() []Genotype {
-	return v.genotypes
+	// The genotypes refer back to this copy of the variant, which has its
+	// header set.
+	xs := make([]Genotype, len(v.genotypes))
+	for i, g := range v.genotypes {
+		g.v = &v
+		xs[i] = g
+	}
+	return xs
 }
 
 func (v Variant) Alleles() []string {
@@ -77,17 +91,70 @@ func (v Variant) AttributeAsFloat64(key string) (float64, error) {
 	return f, nil
 }
 
-// func (v Variant) AttributeAsStringSlice(key string) ([]string, error) {
-
-// }
-
-// func (v Variant) AttributeAsIntSlice(key string) ([]int, error) {
+// AttributeAsStringSlice returns the comma separated elements of an INFO
+// field. If the header defines the field, the number of elements must match
+// its Number. Missing elements are returned as ".". Elements are
+// percent-decoded for VCF 4.3 and later (see PercentDecode).
+func (v Variant) AttributeAsStringSlice(key string) ([]string, error) {
+	xs, _, err := v.infoValues(key)
+	if err != nil {
+		return nil, err
+	}
+	ys, err := decodeValues(v.header, xs)
+	if err != nil {
+		return nil, v.infoError(key, err)
+	}
+	return ys, nil
+}
 
-// }
+// AttributeAsIntSlice returns the elements of an Integer INFO field. If the
+// header defines the field, the number of elements must match its Number
+// and its Type must be Integer. Missing elements are returned as MissingInt.
+func (v Variant) AttributeAsIntSlice(key string) ([]int, error) {
+	xs, def, err := v.infoValues(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkType(def, "Integer"); err != nil {
+		return nil, v.infoError(key, err)
+	}
+	ys, err := parseInts(xs)
+	if err != nil {
+		return nil, v.infoError(key, err)
+	}
+	return ys, nil
+}
 
-// func (v Variant) AttributeAsFloat64Slice(key string) ([]float64, error) {
+// AttributeAsFloat64Slice returns the elements of a Float or Integer INFO
+// field. If the header defines the field, the number of elements must match
+// its Number. Missing elements are returned as NaN (see IsMissingFloat).
+func (v Variant) AttributeAsFloat64Slice(key string) ([]float64, error) {
+	xs, def, err := v.infoValues(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkType(def, "Float", "Integer"); err != nil {
+		return nil, v.infoError(key, err)
+	}
+	ys, err := parseFloats(xs)
+	if err != nil {
+		return nil, v.infoError(key, err)
+	}
+	return ys, nil
+}
 
-// }
+// AttributeAsFlag returns true if the Flag INFO field key is set. It returns
+// an error if the header declares key with a Type other than Flag.
+func (v Variant) AttributeAsFlag(key string) (bool, error) {
+	if v.header != nil {
+		if def, ok := v.header.definition("INFO", key); ok {
+			if err := checkType(&def, "Flag"); err != nil {
+				return false, v.infoError(key, err)
+			}
+		}
+	}
+	return v.HasAttribute(key), nil
+}
 
 func (v Variant) IsFiltered() bool {
 	switch len(v.Filter) {
@@ -139,6 +206,15 @@ const (
 	MIXED
 )
 
+var typeNames = []string{"NO_VARIATION", "SNP", "MNP", "INDEL", "SYMBOLIC", "MIXED"}
+
+func (t Type) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+	return typeNames[t]
+}
+
 func biallelicType(ref, alt string) Type {
 	if containsAny(alt, []string{"*", "<", "[", "]", "."}) {
 		return SYMBOLIC
@@ -161,6 +237,55 @@ func containsAny(s string, set []string) bool {
 	return false
 }
 
+// Start returns the 1-based position of the first reference base covered
+// by the variant, Pos. For indels and symbolic alleles this is the anchor
+// base before the event, as it is for tabix and bcftools.
+func (v Variant) Start() int {
+	return v.Pos
+}
+
+// End returns the 1-based position of the last reference base covered by
+// the variant: END if it is present, otherwise the end of the longest
+// symbolic DEL, DUP, INV or CNV allele given by SVLEN (see
+// StructuralVariant), or the last base of the REF allele. An insertion
+// covers only its anchor base.
+func (v Variant) End() int {
+	end := v.Pos + len(v.Ref) - 1
+	if e, err := v.AttributeAsInt("END"); err == nil {
+		if e > end {
+			end = e
+		}
+		return end
+	}
+	if e := v.svLengthEnd(); e > end {
+		end = e
+	}
+	return end
+}
+
+// Start0 returns the 0-based start of the variant (see Start).
+func (v Variant) Start0() int {
+	return v.Pos - 1
+}
+
+// End0 returns the 0-based, exclusive end of the variant (see End), so the
+// variant covers the half-open interval [Start0, End0).
+func (v Variant) End0() int {
+	return v.End()
+}
+
+// Overlaps returns true if the variant covers any position from start to
+// end of chrom, where start and end are 1-based and inclusive.
+func (v Variant) Overlaps(chrom string, start, end int) bool {
+	return v.Chrom == chrom && v.Start() <= end && v.End() >= start
+}
+
+// Contains returns true if the variant covers the 1-based position pos of
+// chrom.
+func (v Variant) Contains(chrom string, pos int) bool {
+	return v.Overlaps(chrom, pos, pos)
+}
+
 func (v Variant) IsSNP() bool {
 	return v.Type() == SNP
 }
@@ -170,7 +295,6 @@ func (v Variant) IsINDEL() bool {
 }
 
 // Type()
-// Start(), End()
 // IsNotFiltered(), IsIndel(), IsComplexIndel(), IsBiallelic(), IsSimpleDeletion(), IsSymbolicOrSV(), IsVariant(), IsSNP(), IsSimpleIndel()
 
 // v := vcf.NewVariant(&header)
@@ -192,10 +316,19 @@ func (v *Variant) AddGenotype(g Genotype) error {
 	return nil
 }
 
+// AsVCFLine returns v as a VCF record, without a trailing newline. INFO
+// fields are written in the order they were read, followed by any others in
+// the order of their header definitions and then by key. Characters that
+// would end an INFO or FORMAT value, such as ";" in INFO, are
+// percent-encoded.
 func (v Variant) AsVCFLine() string {
 	info := []string{}
-	for k, v := range v.Info {
-		info = append(info, strings.Join([]string{k, v}, "="))
+	for _, k := range v.infoOrder() {
+		if def := v.definition("INFO", k); def != nil && def.Get("Type") == "Flag" && v.Info[k] == "1" {
+			info = append(info, k)
+			continue
+		}
+		info = append(info, k+"="+infoEscaper.Replace(v.Info[k]))
 	}
 	qual := v.Qual
 	if qual == "" {
@@ -205,6 +338,9 @@ func (v Variant) AsVCFLine() string {
 	if len(v.Filter) > 0 {
 		filter = strings.Join(v.Filter, ";")
 	}
+	if len(info) == 0 {
+		info = []string{"."}
+	}
 	cols := []string{
 		v.Chrom,
 		fmt.Sprint(v.Pos),
@@ -224,26 +360,41 @@ func (v Variant) AsVCFLine() string {
 	return strings.Join(cols, "\t")
 }
 
-func (v Variant) CsqKeys() ([]string, error) {
-	if v.header == nil {
-		panic("missing header in VCF")
+// infoOrder returns the keys of v.Info in the order they are written (see
+// AsVCFLine).
+func (v Variant) infoOrder() []string {
+	keys := make([]string, 0, len(v.Info))
+	seen := make(map[string]bool, len(v.Info))
+	add := func(k string) {
+		if _, ok := v.Info[k]; ok && !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
 	}
-	for _, i := range v.header.Infos() {
-		if i.ID() != "CSQ" {
-			continue
+	for _, k := range v.infoKeys {
+		add(k)
+	}
+	if v.header != nil && len(keys) < len(v.Info) {
+		for _, l := range v.header.Infos() {
+			add(l.ID())
+		}
+	}
+	var rest []string
+	for k := range v.Info {
+		if !seen[k] {
+			rest = append(rest, k)
 		}
-		return parseCsq(i.Get("Description")), nil
 	}
-	return []string{}, errors.New("no CSQ INFO record in VCF header")
+	sort.Strings(rest)
+	return append(keys, rest...)
 }
 
-func parseCsq(s string) []string {
-	s = strings.TrimPrefix(s, "Consequence annotations from Ensembl VEP. Format: ")
-	return strings.Split(s, "|")
+func parseVcfLine(line string, samples []string) (Variant, error) {
+	return parseVcfColumns(strings.Split(line, "\t"), samples)
 }
 
-func parseVcfLine(line string, samples []string) (Variant, error) {
-	bits := strings.Split(line, "\t")
+// parseVcfColumns parses the tab separated columns of a VCF record.
+func parseVcfColumns(bits []string, samples []string) (Variant, error) {
 	if len(bits) < 8 {
 		return Variant{}, fmt.Errorf("less than 8 columns found in VCF line")
 	}
@@ -252,13 +403,20 @@ func parseVcfLine(line string, samples []string) (Variant, error) {
 		return Variant{}, fmt.Errorf("unable to convert position: %w", err)
 	}
 	info := make(map[string]string)
+	var infoKeys, explicitOnes []string
 	// A '.' in the INFO column indicates that there are no fields, do not
 	// add this to the map!
 	if bits[7] != "." {
 		for _, i := range strings.Split(bits[7], ";") {
 			bits := strings.SplitN(i, "=", 2)
+			if _, ok := info[bits[0]]; !ok {
+				infoKeys = append(infoKeys, bits[0])
+			}
 			if len(bits) == 2 {
 				info[bits[0]] = bits[1]
+				if bits[1] == "1" {
+					explicitOnes = append(explicitOnes, bits[0])
+				}
 			} else {
 				info[bits[0]] = "1"
 			}
@@ -278,23 +436,39 @@ func parseVcfLine(line string, samples []string) (Variant, error) {
 		Alt:   strings.Split(bits[4], ","),
 		Qual:  bits[5],
 		// Filter: strings.Split(bits[6], ";"),
-		Filter: filter,
-		Info:   info,
+		Filter:       filter,
+		Info:         info,
+		infoKeys:     infoKeys,
+		explicitOnes: explicitOnes,
+	}
+	if len(bits) < 9 {
+		// A sites-only VCF.
+		return vc, nil
 	}
-	if len(bits) >= 9 {
-		vc.Format = strings.Split(bits[8], ":")
+	vc.Format = strings.Split(bits[8], ":")
+	if len(bits)-9 != len(samples) {
+		return Variant{}, fmt.Errorf("%s:%d: record has %d sample columns but the header has %d samples", vc.Chrom, vc.Pos, len(bits)-9, len(samples))
 	}
 	for i, gt := range bits[9:] {
 		xs := strings.Split(gt, ":")
+		if len(xs) > len(vc.Format) {
+			return Variant{}, fmt.Errorf("%s:%d: sample %s has %d fields, but FORMAT has %d", vc.Chrom, vc.Pos, samples[i], len(xs), len(vc.Format))
+		}
+		// Trailing fields may be dropped, they are missing values.
 		vs := make(map[string]string)
-		for j, x := range xs {
-			vs[vc.Format[j]] = x
+		for j, k := range vc.Format {
+			vs[k] = "."
+			if j < len(xs) {
+				vs[k] = xs[j]
+			}
 		}
 		g, err := NewGenotype(samples[i], vs)
 		if err != nil {
 			return Variant{}, fmt.Errorf("unable to create genotype: %w", err)
 		}
-		vc.AddGenotype(g)
+		if err := vc.AddGenotype(g); err != nil {
+			return Variant{}, err
+		}
 	}
 
 	return vc, nil
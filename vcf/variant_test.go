@@ -1,7 +1,9 @@
 package vcf
 
 import (
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -103,7 +105,7 @@ func TestVariant_IsFiltered(t *testing.T) {
 	}
 }
 
-func Test_parseCsq(t *testing.T) {
+func Test_parseAnnotationKeys(t *testing.T) {
 	type args struct {
 		s string
 	}
@@ -126,11 +128,21 @@ func Test_parseCsq(t *testing.T) {
 				"BAM_EDIT", "HGVS_OFFSET", "HGVSg",
 			},
 		},
+		{
+			"custom fields",
+			args{"Consequence annotations from Ensembl VEP. Format: SYMBOL|Allele|Consequence"},
+			[]string{"SYMBOL", "Allele", "Consequence"},
+		},
+		{
+			"SnpEff",
+			args{"Functional annotations: 'Allele | Annotation | Annotation_Impact | Gene_Name | Gene_ID' "},
+			[]string{"Allele", "Annotation", "Annotation_Impact", "Gene_Name", "Gene_ID"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := parseCsq(tt.args.s); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("parseCsq() = %v, want %v", got, tt.want)
+			if got := parseAnnotationKeys(tt.args.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAnnotationKeys() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -192,6 +204,18 @@ func TestVariant_CsqKeys(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"no header",
+			fields{
+				Chrom: "1",
+				Pos:   1000,
+				Ref:   "A",
+				Alt:   []string{"T"},
+				Info:  make(map[string]string),
+			},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -219,3 +243,207 @@ func TestVariant_CsqKeys(t *testing.T) {
 		})
 	}
 }
+
+const typedTestVCF = `##fileformat=VCFv4.2
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##INFO=<ID=RAF,Number=R,Type=Float,Description="Allele frequencies">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP membership">
+##INFO=<ID=GENES,Number=.,Type=String,Description="Genes">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled genotype likelihoods">
+##FORMAT=<ID=VAF,Number=A,Type=Float,Description="Variant allele frequency">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3
+1	100	.	A	C,G	.	PASS	DP=20;AC=1,.;AF=0.5,0.25;RAF=0.25,0.5,0.25;DB;GENES=A,B,C	GT:AD:PL:VAF	0/1:10,10,0:0,10,100,20,200,300:0.5,0	1:5,.,1:0,1,2:.,.	./.:.:.:.
+1	200	.	A	C	.	PASS	DP=x;AC=1,2;AF=high;RAF=.	GT:AD:PL:VAF	0/1:10,10,0:0,10:0.5	0/1:1:0,1,2:x	0/1:1,1:0,1,2:0.5
+`
+
+// typedTestVariants returns the variants in typedTestVCF.
+func typedTestVariants(t *testing.T) []Variant {
+	t.Helper()
+	s, err := NewReader(strings.NewReader(typedTestVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scanAll(t, s)
+}
+
+func TestVariant_AttributeAsIntSlice(t *testing.T) {
+	vs := typedTestVariants(t)
+	tests := []struct {
+		name    string
+		v       Variant
+		key     string
+		want    []int
+		wantErr bool
+	}{
+		{"Number=1", vs[0], "DP", []int{20}, false},
+		{"missing element", vs[0], "AC", []int{1, MissingInt}, false},
+		{"Float", vs[0], "AF", nil, true},
+		{"no such key", vs[0], "XX", nil, true},
+		{"not an integer", vs[1], "DP", nil, true},
+		{"wrong number", vs[1], "AC", nil, true},
+		{"no header", Variant{Info: map[string]string{"AC": "1,2,3"}}, "AC", []int{1, 2, 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.v.AttributeAsIntSlice(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Variant.AttributeAsIntSlice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variant.AttributeAsIntSlice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariant_AttributeAsFloat64Slice(t *testing.T) {
+	vs := typedTestVariants(t)
+	tests := []struct {
+		name    string
+		v       Variant
+		key     string
+		want    []float64
+		wantErr bool
+	}{
+		{"Number=A", vs[0], "AF", []float64{0.5, 0.25}, false},
+		{"Number=R", vs[0], "RAF", []float64{0.25, 0.5, 0.25}, false},
+		{"Integer", vs[0], "AC", []float64{1, math.NaN()}, false},
+		{"String", vs[0], "GENES", nil, true},
+		{"not a float", vs[1], "AF", nil, true},
+		{"missing", vs[1], "RAF", []float64{math.NaN()}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.v.AttributeAsFloat64Slice(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Variant.AttributeAsFloat64Slice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Variant.AttributeAsFloat64Slice() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] && !(IsMissingFloat(got[i]) && IsMissingFloat(tt.want[i])) {
+					t.Errorf("Variant.AttributeAsFloat64Slice() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVariant_AttributeAsStringSlice(t *testing.T) {
+	vs := typedTestVariants(t)
+	got, err := vs[0].AttributeAsStringSlice("GENES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Variant.AttributeAsStringSlice() = %v, want %v", got, want)
+	}
+	_, err = vs[1].AttributeAsStringSlice("AC")
+	if err == nil || !strings.Contains(err.Error(), "1:200: INFO AC") {
+		t.Errorf("Variant.AttributeAsStringSlice() error = %v, want error naming the field and position", err)
+	}
+}
+
+func TestVariant_AttributeAsFlag(t *testing.T) {
+	vs := typedTestVariants(t)
+	tests := []struct {
+		name    string
+		v       Variant
+		key     string
+		want    bool
+		wantErr bool
+	}{
+		{"set", vs[0], "DB", true, false},
+		{"not set", vs[1], "DB", false, false},
+		{"not a flag", vs[0], "DP", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.v.AttributeAsFlag(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Variant.AttributeAsFlag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Variant.AttributeAsFlag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariant_coordinates(t *testing.T) {
+	tests := []struct {
+		name       string
+		v          Variant
+		start, end int
+	}{
+		{"snp", Variant{Pos: 100, Ref: "A", Alt: []string{"C"}}, 100, 100},
+		{"mnp", Variant{Pos: 100, Ref: "AT", Alt: []string{"GC"}}, 100, 101},
+		{"insertion", Variant{Pos: 100, Ref: "A", Alt: []string{"ATT"}}, 100, 100},
+		{"deletion", Variant{Pos: 100, Ref: "ATT", Alt: []string{"A"}}, 100, 102},
+		{"END", Variant{Pos: 100, Ref: "A", Alt: []string{"<NON_REF>"}, Info: map[string]string{"END": "150"}}, 100, 150},
+		{"SVLEN", Variant{Pos: 100, Ref: "A", Alt: []string{"<DEL>"}, Info: map[string]string{"SVLEN": "-50"}}, 100, 150},
+		{"SVLEN insertion", Variant{Pos: 100, Ref: "A", Alt: []string{"<INS>"}, Info: map[string]string{"SVLEN": "50"}}, 100, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.Start(); got != tt.start {
+				t.Errorf("Variant.Start() = %v, want %v", got, tt.start)
+			}
+			if got := tt.v.End(); got != tt.end {
+				t.Errorf("Variant.End() = %v, want %v", got, tt.end)
+			}
+			if got := tt.v.Start0(); got != tt.start-1 {
+				t.Errorf("Variant.Start0() = %v, want %v", got, tt.start-1)
+			}
+			if got := tt.v.End0(); got != tt.end {
+				t.Errorf("Variant.End0() = %v, want %v", got, tt.end)
+			}
+		})
+	}
+}
+
+func TestVariant_Overlaps(t *testing.T) {
+	del := Variant{Chrom: "1", Pos: 100, Ref: "ATT", Alt: []string{"A"}}
+	ins := Variant{Chrom: "1", Pos: 100, Ref: "A", Alt: []string{"ATT"}}
+	tests := []struct {
+		name       string
+		v          Variant
+		chrom      string
+		start, end int
+		want       bool
+	}{
+		{"deleted bases", del, "1", 101, 102, true},
+		{"anchor base", del, "1", 90, 100, true},
+		{"after", del, "1", 103, 110, false},
+		{"before", del, "1", 90, 99, false},
+		{"other chromosome", del, "2", 100, 102, false},
+		{"insertion anchor", ins, "1", 100, 100, true},
+		{"after insertion", ins, "1", 101, 110, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.Overlaps(tt.chrom, tt.start, tt.end); got != tt.want {
+				t.Errorf("Variant.Overlaps() = %v, want %v", got, tt.want)
+			}
+			if tt.start == tt.end {
+				if got := tt.v.Contains(tt.chrom, tt.start); got != tt.want {
+					t.Errorf("Variant.Contains() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVariant_AsVCFLine_infoOrder(t *testing.T) {
+	v := typedTestVariants(t)[1]
+	v.Info = map[string]string{"GENES": "A", "DB": "1", "AF": "high", "DP": "x", "XX": "1", "AC": "1,2"}
+	want := "1\t200\t.\tA\tC\t.\t.\tDP=x;AC=1,2;AF=high;DB;GENES=A;XX=1"
+	if got := v.AsVCFLine(); !strings.HasPrefix(got, want+"\t") {
+		t.Errorf("Variant.AsVCFLine() = %q, want prefix %q", got, want)
+	}
+}
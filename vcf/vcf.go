@@ -2,6 +2,7 @@ package vcf
 
 import (
 	"bufio"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/biogo/hts/bgzf"
 )
 
 type VCF struct {
@@ -31,13 +34,80 @@ type Scanner struct {
 	vcf        VCF
 	cmd        *exec.Cmd
 	stdout     io.ReadCloser
+	r          io.ReadCloser
+	regions    []Region
+	idx        *Index
+	bg         *bgzf.Reader
+	bcf        *bcfDict
+	region     int
 	token      Variant
 	err        error
-	scanner    *bufio.Scanner
+	records    recordReader
+	config     readerConfig
+	filter     *Expr
 	scanCalled bool
+	eof        bool
 	done       bool
 }
 
+// fileReader reads the decompressed contents of a VCF file.
+type fileReader struct {
+	*bufio.Reader
+	closers []io.Closer
+}
+
+// openFile opens a VCF file for reading. Files compressed with gzip or BGZF
+// are decompressed transparently.
+func openFile(path string) (*fileReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can not open file: %w", err)
+	}
+	fr, err := newFileReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fr.closers = append(fr.closers, f)
+	return fr, nil
+}
+
+// newFileReader wraps r in a fileReader, decompressing it if it starts with
+// the gzip magic number. Closing the returned reader does not close r.
+func newFileReader(r io.Reader) (*fileReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("can not read file: %w", err)
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("can not decompress file: %w", err)
+		}
+		return &fileReader{Reader: bufio.NewReader(gz), closers: []io.Closer{gz}}, nil
+	}
+	return &fileReader{Reader: br}, nil
+}
+
+// isBCF returns true if the decompressed contents start with the BCF magic
+// number.
+func (f *fileReader) isBCF() bool {
+	magic, _ := f.Peek(3)
+	return string(magic) == "BCF"
+}
+
+// Close closes the file and any decompressors.
+func (f *fileReader) Close() error {
+	var ret error
+	for _, c := range f.closers {
+		if err := c.Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
 func findBcftools() (string, error) {
 	exe, err := exec.LookPath("bcftools")
 	if err != nil {
@@ -56,24 +126,108 @@ func findBcftools() (string, error) {
 	return exe, nil
 }
 
+// NewScanner creates a Scanner that reads the variants in v. Plain text and
+// gzip/BGZF compressed VCF and BCF files are decoded natively, the format is
+// detected from the contents of the file.
+//
+// If any loc regions are given, for example "chr1", "chr1:10000" or
+// "chr1:10000-20000" (see ParseRegion), only variants overlapping at least
+// one of them are returned (see Region.Overlaps). Each variant is returned
+// at most once, in file order. If the file has a tabix or CSI index (see
+// OpenIndex) it is used to seek directly to the variants in each region,
+// otherwise every variant in the file is read and tested.
 func NewScanner(v VCF, loc ...string) (*Scanner, error) {
 	var err error
 	s := &Scanner{vcf: v}
-	exe, err := findBcftools()
+	s.regions, err = parseRegions(loc)
 	if err != nil {
 		return nil, err
 	}
-	s.cmd = exec.Command(exe, "view", "-H", v.file)
-	s.stdout, err = s.cmd.StdoutPipe()
+	f, err := openFile(v.file)
 	if err != nil {
-		return s, err
+		return nil, err
+	}
+	if f.isBCF() {
+		s.bcf = newBCFDict(v.Header)
+	}
+	if len(s.regions) > 0 {
+		idx, err := OpenIndex(v.file)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			f.Close()
+			return nil, err
+		}
+		if idx != nil {
+			f.Close()
+			if s.bcf != nil {
+				// BCF indexes refer to contigs by their index in
+				// the header dictionary.
+				idx.setNames(s.bcf.contigs)
+			}
+			return newIndexedScanner(s, idx)
+		}
+	}
+	if s.bcf != nil {
+		if _, err := readBCFHeader(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	s.r = f
+	return s, nil
+}
+
+// newIndexedScanner sets up s to use idx to read the variants in its
+// regions from a BGZF compressed file.
+func newIndexedScanner(s *Scanner, idx *Index) (*Scanner, error) {
+	f, err := os.Open(s.vcf.file)
+	if err != nil {
+		return nil, fmt.Errorf("can not open file: %w", err)
+	}
+	bg, err := bgzf.NewReader(f, 1)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("can not read BGZF file: %w", err)
 	}
+	s.r = &fileReader{closers: []io.Closer{bg, f}}
+	s.regions = idx.fileOrder(s.regions)
+	s.idx = idx
+	s.bg = bg
 	return s, nil
 }
 
+// NewReader creates a Scanner that reads a VCF or BCF from r, for example,
+// stdin or an HTTP response body. The header is parsed immediately and is
+// available from the Scanner's Header method. Compressed input is
+// decompressed transparently. Closing the Scanner does not close r.
+func NewReader(r io.Reader) (*Scanner, error) {
+	f, err := newFileReader(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scanner{r: f}
+	if f.isBCF() {
+		s.vcf.Header, err = readBCFHeader(f)
+		s.bcf = newBCFDict(s.vcf.Header)
+	} else {
+		s.vcf.Header, err = readHeader(f.Reader)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read header: %w", err)
+	}
+	return s, nil
+}
+
+// NewScannerFromCommand creates a Scanner that reads variants from the
+// standard output of cmd, which must not include the VCF header. Variants are
+// restricted to the loc regions as they are for NewScanner.
 func NewScannerFromCommand(cmd *exec.Cmd, loc ...string) (*Scanner, error) {
 	var err error
 	s := &Scanner{}
+	s.regions, err = parseRegions(loc)
+	if err != nil {
+		return nil, err
+	}
 	s.cmd = cmd
 	s.stdout, err = s.cmd.StdoutPipe()
 	if err != nil {
@@ -82,43 +236,202 @@ func NewScannerFromCommand(cmd *exec.Cmd, loc ...string) (*Scanner, error) {
 	return s, nil
 }
 
+// recordReader reads the variant records that follow the header.
+type recordReader interface {
+	// read returns the next variant, or io.EOF after the last one.
+	read() (Variant, error)
+}
+
+// newRecordReader returns a recordReader for the records in r.
+func (s *Scanner) newRecordReader(r io.Reader) recordReader {
+	if s.bcf != nil {
+		return newBCFReader(r, s.bcf, s.vcf.Header.Samples)
+	}
+	return newTextReader(r, s.vcf.Header.Samples, &s.config)
+}
+
+// textReader reads the records of a text VCF.
+type textReader struct {
+	scanner *bufio.Scanner
+	samples []string
+	config  *readerConfig
+}
+
+// maxLineSize is the length of the longest VCF line that can be read. Lines
+// of wide multi-sample records can be far longer than bufio.Scanner's
+// default limit; the buffer only grows as long lines are read.
+const maxLineSize = 100000000
+
+func newTextReader(r io.Reader, samples []string, config *readerConfig) *textReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 100000), maxLineSize)
+	return &textReader{scanner: scanner, samples: samples, config: config}
+}
+
+func (t *textReader) read() (Variant, error) {
+	for t.scanner.Scan() {
+		line := t.scanner.Text()
+		// Only the native reader sees the header, bcftools is asked to
+		// omit it.
+		if strings.HasPrefix(line, headerIndicator) {
+			continue
+		}
+		v, skip, err := t.config.parse(strings.Split(line, "\t"), t.samples)
+		if skip {
+			continue
+		}
+		return v, err
+	}
+	if err := t.scanner.Err(); err != nil {
+		return Variant{}, err
+	}
+	return Variant{}, io.EOF
+}
+
 func (s *Scanner) Scan() bool {
 	if s.done {
 		return false
 	}
+	if s.idx != nil {
+		return s.scanIndexed()
+	}
 	if !s.scanCalled {
-		if err := s.cmd.Start(); err != nil {
-			s.err = err
-			return false
+		var r io.Reader = s.r
+		if s.cmd != nil {
+			if err := s.cmd.Start(); err != nil {
+				s.err = err
+				return false
+			}
+			r = s.stdout
 		}
-
-		s.scanner = bufio.NewScanner(s.stdout)
-		buf := make([]byte, 0, 100000)
-		s.scanner.Buffer(buf, 100000)
+		s.records = s.newRecordReader(r)
 		s.scanCalled = true
 	}
-	for s.scanner.Scan() {
-		token, err := parseVcfLine(s.scanner.Text(), s.vcf.Header.Samples)
+	for {
+		token, err := s.records.read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
 			s.err = err
+			s.Close()
 			return false
 		}
+		if len(s.regions) > 0 && !overlapsAny(s.regions, token) {
+			continue
+		}
 		token.header = &s.vcf.Header
+		if ok, err := s.matches(token); err != nil {
+			s.err = err
+			s.Close()
+			return false
+		} else if !ok {
+			continue
+		}
 		s.token = token
 		return true
 	}
+	s.eof = true
+	if err := s.Close(); err != nil && s.err == nil {
+		s.err = err
+	}
 	return false
 }
 
+// scanIndexed reads the variants in each region in turn, seeking to the
+// first chunk of the region and reading until the variants pass its end.
+func (s *Scanner) scanIndexed() bool {
+	s.scanCalled = true
+	for s.region < len(s.regions) {
+		r := s.regions[s.region]
+		if s.records == nil {
+			chunks, err := s.idx.Chunks(r)
+			if err != nil {
+				s.err = err
+				s.Close()
+				return false
+			}
+			if len(chunks) == 0 {
+				s.region++
+				continue
+			}
+			if err := s.bg.Seek(chunks[0].Begin); err != nil {
+				s.err = fmt.Errorf("unable to seek to %s: %w", r, err)
+				s.Close()
+				return false
+			}
+			s.records = s.newRecordReader(s.bg)
+		}
+		for {
+			token, err := s.records.read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				s.err = err
+				s.Close()
+				return false
+			}
+			if token.Chrom != r.Chrom || token.Pos > r.End {
+				break
+			}
+			// A variant spanning several regions has already been
+			// returned for the first one.
+			if !r.Overlaps(token) || overlapsAny(s.regions[:s.region], token) {
+				continue
+			}
+			token.header = &s.vcf.Header
+			if ok, err := s.matches(token); err != nil {
+				s.err = err
+				s.Close()
+				return false
+			} else if !ok {
+				continue
+			}
+			s.token = token
+			return true
+		}
+		s.records = nil
+		s.region++
+	}
+	s.eof = true
+	if err := s.Close(); err != nil && s.err == nil {
+		s.err = err
+	}
+	return false
+}
+
+// Close releases the resources held by the scanner. It is called
+// automatically once all variants have been read, but must be called if
+// scanning is abandoned early.
+func (s *Scanner) Close() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	if s.r != nil {
+		return s.r.Close()
+	}
+	if s.cmd != nil && s.scanCalled {
+		if !s.eof {
+			s.cmd.Process.Kill()
+		}
+		return s.cmd.Wait()
+	}
+	return nil
+}
+
+// Header returns the header of the VCF being scanned.
+func (s *Scanner) Header() Header {
+	return s.vcf.Header
+}
+
 func (s *Scanner) Variant() Variant {
 	return s.token
 }
 
 func (s *Scanner) Err() error {
-	if s.err != nil {
-		return s.err
-	}
-	return s.scanner.Err()
+	return s.err
 }
 
 func CreateIndex(f string) error {
@@ -1 +1,194 @@
 package vcf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testVCF = `##fileformat=VCFv4.2
+##FILTER=<ID=PASS,Description="All filters passed">
+##FILTER=<ID=LowQual,Description="Low quality">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele Frequency">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Read depth">
+##contig=<ID=1,length=249250621>
+##contig=<ID=2,length=243199373>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	rs1	A	C	50	PASS	DP=20;AF=0.5	GT:DP	0/1:10	0/0:10
+1	200	.	AT	A	30	LowQual	DP=5;AF=0.25	GT:DP	0/0:3	0/1:2
+2	50	.	G	T,C	99	PASS	DP=40;AF=0.25,0.25	GT:DP	1/2:20	0/0:20
+`
+
+// writeTestFile writes content to a file called name in a temporary
+// directory, compressing it with gzip if name ends in .gz.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if filepath.Ext(name) == ".gz" {
+		gz := gzip.NewWriter(f)
+		if _, err := gz.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// scanAll returns all the variants from s.
+func scanAll(t *testing.T, s *Scanner) []Variant {
+	t.Helper()
+	xs := []Variant{}
+	for s.Scan() {
+		xs = append(xs, s.Variant())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("scanning failed: %v", err)
+	}
+	return xs
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"vcf", "test.vcf"},
+		{"vcf.gz", "test.vcf.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.file, testVCF)
+			v, err := New(path)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := v.Header.Version(); got != 4.2 {
+				t.Errorf("Header.Version() = %v, want 4.2", got)
+			}
+			if got, want := v.Header.Samples, []string{"S1", "S2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Header.Samples = %v, want %v", got, want)
+			}
+			if got := len(v.Header.Infos()); got != 2 {
+				t.Errorf("len(Header.Infos()) = %d, want 2", got)
+			}
+			if got := len(v.Header.Contigs()); got != 2 {
+				t.Errorf("len(Header.Contigs()) = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestNew_missingFile(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.vcf"))
+	if err == nil {
+		t.Error("New() expected error for missing file")
+	}
+}
+
+func TestScanner(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"vcf", "test.vcf"},
+		{"vcf.gz", "test.vcf.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.file, testVCF)
+			v, err := New(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewScanner(v)
+			if err != nil {
+				t.Fatalf("NewScanner() error = %v", err)
+			}
+			got := scanAll(t, s)
+			if len(got) != 3 {
+				t.Fatalf("got %d variants, want 3", len(got))
+			}
+			first := got[0]
+			if first.Chrom != "1" || first.Pos != 100 || first.ID != "rs1" || first.Ref != "A" {
+				t.Errorf("first variant = %+v", first)
+			}
+			if first.header == nil || !reflect.DeepEqual(first.header.Samples, v.Header.Samples) {
+				t.Errorf("variant header not set")
+			}
+			g, err := got[2].Sample("S1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			alleles, err := g.Alleles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"T", "C"}; !reflect.DeepEqual(alleles, want) {
+				t.Errorf("Genotype.Alleles() = %v, want %v", alleles, want)
+			}
+			if !reflect.DeepEqual(got[1].Filter, []string{"LowQual"}) {
+				t.Errorf("Filter = %v, want [LowQual]", got[1].Filter)
+			}
+			if s.Scan() {
+				t.Error("Scan() returned true after the last variant")
+			}
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(testVCF))
+	gz.Close()
+	tests := []struct {
+		name    string
+		r       io.Reader
+		want    int
+		wantErr bool
+	}{
+		{"plain", strings.NewReader(testVCF), 3, false},
+		{"gzip", bytes.NewReader(compressed.Bytes()), 3, false},
+		{"header only", strings.NewReader("##fileformat=VCFv4.2\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n"), 0, false},
+		{"no version", strings.NewReader("#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n"), 0, true},
+		{"bcf", strings.NewReader("BCF\x02\x02"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewReader(tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := scanAll(t, s)
+			if len(got) != tt.want {
+				t.Fatalf("got %d variants, want %d", len(got), tt.want)
+			}
+			for _, v := range got {
+				if v.header == nil || !reflect.DeepEqual(v.header.Samples, s.Header().Samples) {
+					t.Errorf("variant header not set")
+				}
+			}
+		})
+	}
+}
@@ -1,34 +1,132 @@
 package vcf
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
+
+	"github.com/biogo/hts/bgzf"
 )
 
 // Writer ...
 type Writer struct {
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	header *Header
-	err    error
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	f          *os.File
+	bg         *bgzf.Writer
+	cw         *countingWriter
+	block      int64
+	path       string
+	index      *indexBuilder
+	header     *Header
+	isBCF      bool
+	bcf        *bcfEncoder
+	sort       bool
+	sortMemory int
+	sorter     *Sorter
+}
+
+// WriterOption configures a Writer created with NewWriter.
+type WriterOption func(*Writer) error
+
+// WithIndex builds an index of the given format while the variants are
+// written, which is written alongside the output file when the Writer is
+// closed. Only BGZF compressed output (.vcf.gz and .bcf) can be indexed and
+// BCF can only be indexed with CSI. Variants must be written in sorted
+// order, or sorted with WithSort; otherwise WriteVariant returns an error for
+// any variant that is out of order.
+func WithIndex(format IndexFormat) WriterOption {
+	return func(w *Writer) error {
+		if w.bg == nil {
+			return errors.New("only BGZF compressed VCF files can be indexed")
+		}
+		if w.isBCF && format == TBI {
+			return errors.New("BCF files can only be indexed with CSI")
+		}
+		if format != NoIndex {
+			w.index = newIndexBuilder(format)
+		}
+		return nil
+	}
+}
+
+// WithSort sorts the variants before they are written (see Sorter), holding
+// about memory bytes of variants in memory, or DefaultSortMemory if memory is
+// zero. The sorted variants are written when the Writer is closed.
+func WithSort(memory int) WriterOption {
+	return func(w *Writer) error {
+		w.sort = true
+		w.sortMemory = memory
+		return nil
+	}
+}
+
+// countingWriter counts the bytes written to the underlying io.Writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// NewWriter creates a Writer that writes to the file f. Plain text (.vcf),
+// BGZF compressed (.vcf.gz) VCF and BCF (.bcf) files are written natively,
+// other formats are encoded by bcftools.
+func NewWriter(f string, opts ...WriterOption) (*Writer, error) {
+	var w *Writer
+	var err error
+	switch {
+	case strings.HasSuffix(f, ".vcf.gz"):
+		w, err = newNativeWriter(f, true)
+	case strings.HasSuffix(f, ".vcf"):
+		w, err = newNativeWriter(f, false)
+	case strings.HasSuffix(f, ".bcf"):
+		w, err = newNativeWriter(f, true)
+		if err == nil {
+			w.isBCF = true
+		}
+	default:
+		w, err = newBcftoolsWriter(f)
+	}
+	if err != nil {
+		return w, err
+	}
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			w.Close()
+			return &Writer{}, err
+		}
+	}
+	return w, nil
 }
 
-// NewWriter ...
-func NewWriter(f string) (*Writer, error) {
-	format := "v"
-	if strings.HasSuffix(f, ".vcf.gz") {
-		format = "z"
+func newNativeWriter(f string, compress bool) (*Writer, error) {
+	file, err := os.Create(f)
+	if err != nil {
+		return &Writer{}, fmt.Errorf("failed to create file: %w", err)
 	}
-	if strings.HasSuffix(f, ".bcf") {
-		format = "b"
+	w := &Writer{f: file, path: f}
+	if compress {
+		w.cw = &countingWriter{w: file}
+		w.bg = bgzf.NewWriter(w.cw, 1)
 	}
+	return w, nil
+}
+
+func newBcftoolsWriter(f string) (*Writer, error) {
 	exe, err := findBcftools()
 	if err != nil {
 		return nil, err
 	}
-	cmd := exec.Command(exe, "view", "--no-version", "-O", format, "-o", f)
+	cmd := exec.Command(exe, "view", "--no-version", "-O", "v", "-o", f)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return &Writer{}, fmt.Errorf("failed to create stdin pipe: %w", err)
@@ -40,53 +138,168 @@ func NewWriter(f string) (*Writer, error) {
 }
 
 // Write ...
-func (w Writer) Write(p []byte) (int, error) {
-	return w.stdin.Write(p)
+func (w *Writer) Write(p []byte) (int, error) {
+	switch {
+	case w.bg != nil:
+		return w.bg.Write(p)
+	case w.f != nil:
+		return w.f.Write(p)
+	case w.stdin != nil:
+		return w.stdin.Write(p)
+	}
+	return 0, errors.New("writer is not open")
 }
 
 // WriteString ...
-func (w Writer) WriteString(s string) (int, error) {
-	return io.WriteString(w.stdin, s)
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
 }
 
 // Close ...
-func (w Writer) Close() error {
-	err := w.stdin.Close()
+func (w *Writer) Close() error {
+	if w.sorter != nil {
+		if err := w.writeSorted(); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	if w.cmd != nil {
+		err := w.stdin.Close()
+		if err != nil {
+			return fmt.Errorf("failed to close stdin: %w", err)
+		}
+		return w.cmd.Wait()
+	}
+	if w.f == nil {
+		return nil
+	}
+	var err error
+	if w.bg != nil {
+		err = w.bg.Close()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	if err != nil || w.index == nil {
+		return err
+	}
+	fi, err := os.Create(w.path + w.index.format.Extension())
 	if err != nil {
-		return fmt.Errorf("failed to close stdin: %w", err)
+		return fmt.Errorf("failed to create index: %w", err)
 	}
-	err = w.cmd.Wait()
-	return err
-	// return w.stdin.Close()
+	if err := w.index.write(fi); err != nil {
+		fi.Close()
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return fi.Close()
+}
+
+// writeSorted writes the variants held by the sorter.
+func (w *Writer) writeSorted() error {
+	s := w.sorter
+	w.sorter = nil
+	defer s.Close()
+	for s.Scan() {
+		if err := w.write(s.Variant()); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("unable to sort variants: %w", err)
+	}
+	return s.Close()
+}
+
+// offset returns the BGZF virtual offset of the next byte written.
+func (w *Writer) offset() (uint64, error) {
+	next, err := w.bg.Next()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(w.block)<<16 | uint64(next), nil
+}
+
+// writeRecord writes an encoded record, adding it to the index if one is
+// being built. Each record starts in a new BGZF block unless it fits into
+// the remainder of the current block.
+func (w *Writer) writeRecord(v Variant, line []byte) error {
+	if w.index == nil {
+		_, err := w.Write(line)
+		return err
+	}
+	// A record that can not be indexed must not be written, so that the
+	// index matches the file.
+	if err := w.index.check(v.Chrom, v.Start0(), v.End0()); err != nil {
+		return err
+	}
+	next, err := w.bg.Next()
+	if err != nil {
+		return err
+	}
+	if next > 0 && next+len(line) > bgzf.BlockSize {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	begin, err := w.offset()
+	if err != nil {
+		return err
+	}
+	next, _ = w.bg.Next()
+	if _, err := w.bg.Write(line); err != nil {
+		return err
+	}
+	// If the record filled a block, the blocks must be written before the
+	// offset of the current block is known.
+	if after, _ := w.bg.Next(); after != next+len(line) {
+		if err := w.bg.Wait(); err != nil {
+			return err
+		}
+		w.block = w.cw.n
+	}
+	end, err := w.offset()
+	if err != nil {
+		return err
+	}
+	return w.index.add(v.Chrom, v.Start0(), v.End0(), begin, end)
+}
+
+// flush writes the current BGZF block so that the next write starts a new
+// one.
+func (w *Writer) flush() error {
+	if err := w.bg.Flush(); err != nil {
+		return err
+	}
+	if err := w.bg.Wait(); err != nil {
+		return err
+	}
+	w.block = w.cw.n
+	return nil
 }
 
 // WriteHeader ...
 func (w *Writer) WriteHeader(h Header) error {
 	w.header = &h
-	var line string
-	line = fmt.Sprintf("##fileformat=VCFv%2.1f", h.version)
-	io.WriteString(w, line+"\n")
-	for _, filter := range h.Filters() {
-		io.WriteString(w, filter.AsVCFString()+"\n")
-	}
-	for _, format := range h.Formats() {
-		io.WriteString(w, format.AsVCFString()+"\n")
-	}
-	for _, info := range h.Infos() {
-		io.WriteString(w, info.AsVCFString()+"\n")
-	}
-	// ALT
-	// for _, alt := range h.Alts() {
-	// 	line := fmt.Sprintf(`##ALT=<ID=%s,Description="%s">`, alt.ID, alt.Description)
-	// 	io.WriteString(w, line+"\n")
-	// }
-	// SAMPLE
-	// PEDIGREE
-	for _, other := range h.Others() {
-		io.WriteString(w, other.AsVCFString()+"\n")
-	}
-	for _, contig := range h.Contigs() {
-		io.WriteString(w, contig.AsVCFString()+"\n")
+	if w.sort {
+		w.sorter = NewSorter(h, w.sortMemory)
+	}
+	lines := []HeaderLine{}
+	if w.isBCF && !hasID(h.Filters(), "PASS") {
+		// PASS is always the first entry in the BCF dictionary.
+		lines = append(lines, StandardHeaderLines()[0])
+	}
+	// The lines are written in their original order, so that reading and
+	// writing a VCF does not change its header.
+	for _, l := range h.lines {
+		if l.Key != "fileformat" {
+			lines = append(lines, l)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "##fileformat=VCFv%.1f\n", h.version)
+	for _, l := range lines {
+		b.WriteString(l.AsVCFString() + "\n")
 	}
 	columns := []string{
 		"#CHROM", "POS", "ID", "REF", "ALT", "QUAL", "FILTER", "INFO",
@@ -95,15 +308,33 @@ func (w *Writer) WriteHeader(h Header) error {
 		columns = append(columns, "FORMAT")
 		columns = append(columns, h.Samples...)
 	}
-	line = strings.Join(columns, "\t")
-	io.WriteString(w, line+"\n")
+	b.WriteString(strings.Join(columns, "\t") + "\n")
+	if w.isBCF {
+		// The dictionaries must be built in the order the lines are
+		// written, not the order they were added to h.
+		w.bcf = newBCFEncoder(Header{lines: lines, Samples: h.Samples})
+		if w.index != nil {
+			w.index.setNames(w.bcf.dict.contigs)
+		}
+		b.WriteByte(0)
+		io.WriteString(w, "BCF\x02\x02")
+		binary.Write(w, binary.LittleEndian, uint32(b.Len()))
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	// The header is kept in its own blocks so that the first record starts
+	// at the beginning of a block.
+	if w.bg != nil {
+		return w.flush()
+	}
 	return nil
 }
 
 // WriteVariant adds the variant to the writer. Returns non-nil error if the
 // variant can not be written or it is invalid, for example, if the writers
 // header defines contigs and its Chrom is not defined in the header.
-func (w Writer) WriteVariant(v Variant) error {
+func (w *Writer) WriteVariant(v Variant) error {
 	// There should be more validation before adding the variant
 	if w.header == nil {
 		return fmt.Errorf("Writer has no header, unable to add variants %v", w.header)
@@ -153,9 +384,27 @@ func (w Writer) WriteVariant(v Variant) error {
 	if !stringSliceEqual(gn, w.header.Samples) {
 		return fmt.Errorf("the genotype samples do not match the samples in the header")
 	}
+	if w.sorter != nil {
+		return w.sorter.Add(v)
+	}
+	return w.write(v)
+}
 
-	_, err := io.WriteString(w, v.AsVCFLine()+"\n")
-	return err
+// write encodes and writes a variant that has been validated.
+func (w *Writer) write(v Variant) error {
+	if w.bcf != nil {
+		rec, err := w.bcf.encode(v)
+		if err != nil {
+			return err
+		}
+		return w.writeRecord(v, rec)
+	}
+	// The header gives the order of any INFO fields added to v and which
+	// are flags.
+	if v.header == nil {
+		v.header = w.header
+	}
+	return w.writeRecord(v, []byte(v.AsVCFLine()+"\n"))
 }
 
 // Do two string slices contain the same elements in the same order?
@@ -0,0 +1,436 @@
+package vcf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		opts    []WriterOption
+		wantErr bool
+	}{
+		{"vcf", "out.vcf", nil, false},
+		{"vcf.gz", "out.vcf.gz", nil, false},
+		{"tbi", "out.vcf.gz", []WriterOption{WithIndex(TBI)}, false},
+		{"csi", "out.vcf.gz", []WriterOption{WithIndex(CSI)}, false},
+		{"bcf", "out.bcf", nil, false},
+		{"bcf csi", "out.bcf", []WriterOption{WithIndex(CSI)}, false},
+		{"uncompressed index", "out.vcf", []WriterOption{WithIndex(TBI)}, true},
+		{"bcf tbi", "out.bcf", []WriterOption{WithIndex(TBI)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			r, err := NewReader(strings.NewReader(testVCF))
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := NewWriter(path, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWriter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := w.WriteHeader(r.Header()); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{}
+			for r.Scan() {
+				v := r.Variant()
+				want = append(want, Region{v.Chrom, v.Pos, v.Pos}.String())
+				if err := w.WriteVariant(v); err != nil {
+					t.Fatalf("Writer.WriteVariant() error = %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Writer.Close() error = %v", err)
+			}
+			v, err := New(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v.Header.Samples, r.Header().Samples) {
+				t.Errorf("Header.Samples = %v, want %v", v.Header.Samples, r.Header().Samples)
+			}
+			s, err := NewScanner(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, v := range scanAll(t, s) {
+				got = append(got, Region{v.Chrom, v.Pos, v.Pos}.String())
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("variants = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriter_WriteVariant_unsorted(t *testing.T) {
+	tests := []struct {
+		name string
+		loci [][2]string
+	}{
+		{"position", [][2]string{{"1", "200"}, {"1", "100"}}},
+		{"contig", [][2]string{{"1", "100"}, {"2", "100"}, {"1", "200"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHeader()
+			path := filepath.Join(t.TempDir(), "out.vcf.gz")
+			w, err := NewWriter(path, WithIndex(TBI))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			w.WriteHeader(h)
+			var err2 error
+			want := []string{}
+			for _, l := range tt.loci {
+				v, err := parseVcfLine(fmt.Sprintf("%s\t%s\t.\tA\tC\t.\t.\t.", l[0], l[1]), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err2 = w.WriteVariant(v); err2 == nil {
+					want = append(want, l[0]+":"+l[1])
+				}
+			}
+			if err2 == nil {
+				t.Error("Writer.WriteVariant() expected error for unsorted variant")
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Writer.Close() error = %v", err)
+			}
+			// The rejected variant must not have been written.
+			v, err := New(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewScanner(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, x := range scanAll(t, s) {
+				got = append(got, x.Chrom+":"+strconv.Itoa(x.Pos))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("written variants = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriter_WriteVariant_sorted(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.vcf.gz")
+	w, err := NewWriter(out, WithIndex(CSI), WithSort(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHeader()
+	if err := w.WriteHeader(h); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range [][2]string{{"2", "100"}, {"1", "200"}, {"1", "100"}} {
+		v, err := parseVcfLine(fmt.Sprintf("%s\t%s\t.\tA\tC\t.\t.\t.", l[0], l[1]), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteVariant(v); err != nil {
+			t.Fatalf("Writer.WriteVariant() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	v, err := New(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScanner(v, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []int{}
+	for _, x := range scanAll(t, s) {
+		got = append(got, x.Pos)
+	}
+	if !reflect.DeepEqual(got, []int{100, 200}) {
+		t.Errorf("indexed query of sorted output = %v, want [100 200]", got)
+	}
+}
+
+// TestWriter_index writes enough variants to span many BGZF blocks and
+// checks that region queries using the index agree with a linear scan.
+func TestWriter_index(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("##fileformat=VCFv4.2\n")
+	b.WriteString("##INFO=<ID=END,Number=1,Type=Integer,Description=\"End position\">\n")
+	b.WriteString("#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n")
+	for _, chrom := range []string{"1", "2"} {
+		for pos := 1; pos <= 200000; pos += 97 {
+			info := "."
+			if pos%10 == 0 {
+				info = fmt.Sprintf("END=%d", pos+40000)
+			}
+			fmt.Fprintf(&b, "%s\t%d\t.\tA\tC\t.\tPASS\t%s\n", chrom, pos, info)
+		}
+	}
+	for _, format := range []IndexFormat{TBI, CSI} {
+		t.Run(format.Extension(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.vcf.gz")
+			writeIndexedTestFile(t, path, b.String(), format)
+			v, err := New(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, loc := range []string{"1:150000-150100", "2:1-20000", "2:99000", "1:199990-"} {
+				indexed, err := NewScanner(v, loc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if indexed.idx == nil {
+					t.Fatal("index was not used")
+				}
+				r, _ := ParseRegion(loc)
+				linear, err := NewReader(strings.NewReader(b.String()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := []int{}
+				for _, v := range scanAll(t, linear) {
+					if r.Overlaps(v) {
+						want = append(want, v.Pos)
+					}
+				}
+				got := []int{}
+				for _, v := range scanAll(t, indexed) {
+					got = append(got, v.Pos)
+				}
+				if len(want) == 0 || !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: got %d variants, want %d", loc, len(got), len(want))
+				}
+			}
+		})
+	}
+}
+
+// TestWriter_bcf checks that variants written as BCF are read back the same
+// as those written as text.
+func TestWriter_bcf(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"testVCF", testVCF},
+		{"bcfTestVCF", bcfTestVCF},
+		{"indexTestVCF", indexTestVCF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"out.vcf", "out.bcf"} {
+				r, err := NewReader(strings.NewReader(tt.content))
+				if err != nil {
+					t.Fatal(err)
+				}
+				w, err := NewWriter(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := w.WriteHeader(r.Header()); err != nil {
+					t.Fatal(err)
+				}
+				for r.Scan() {
+					if err := w.WriteVariant(r.Variant()); err != nil {
+						t.Fatalf("Writer.WriteVariant() error = %v", err)
+					}
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			read := func(name string) []Variant {
+				v, err := New(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				s, err := NewScanner(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return scanAll(t, s)
+			}
+			want, got := read("out.vcf"), read("out.bcf")
+			if len(got) != len(want) {
+				t.Fatalf("got %d variants, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if g, w := decodedFields(got[i]), decodedFields(want[i]); !reflect.DeepEqual(g, w) {
+					t.Errorf("variant %d = %v, want %v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestWriter_WriteVariant_bcfErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"unknown contig", "3\t100\t.\tA\tC\t.\t.\tDP=1\tGT\t0/1\t0/1"},
+		{"bad integer", "1\t100\t.\tA\tC\t.\t.\tDP=x\tGT\t0/1\t0/1"},
+		{"bad qual", "1\t100\t.\tA\tC\tq\t.\tDP=1\tGT\t0/1\t0/1"},
+		{"INFO Number", "1\t100\t.\tA\tC\t.\t.\tAF=0.5,0.5\tGT\t0/1\t0/1"},
+		{"FORMAT Number", "1\t100\t.\tA\tC\t.\t.\tDP=1\tGT:DP\t0/1:1,2\t0/1:3"},
+	}
+	r, err := NewReader(strings.NewReader(testVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := r.Header()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewWriter(filepath.Join(t.TempDir(), "out.bcf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			if err := w.WriteHeader(h); err != nil {
+				t.Fatal(err)
+			}
+			v, err := parseVcfLine(tt.line, h.Samples)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteVariant(v); err == nil {
+				t.Error("Writer.WriteVariant() expected error")
+			}
+		})
+	}
+}
+
+const roundTripVCF = `##fileformat=VCFv4.2
+##FILTER=<ID=LowQual,Description="Low quality, see C:\filters\lowqual">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele Frequency">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP membership">
+##INFO=<ID=MQ,Number=1,Type=Float,Description="Mapping quality">
+##INFO=<ID=SOR,Number=1,Type=Float,Description="Strand odds ratio">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##contig=<ID=1,length=249250621>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+1	100	rs1	A	C	50	.	SOR=0.7;MQ=60;DB;AF=0.5;DP=20	GT	0/1
+1	200	.	AT	A	30	LowQual	DP=5;DB;SOR=1.2;AF=0.25;MQ=40	GT	0/0
+`
+
+func TestWriter_WriteVariant_roundTrip(t *testing.T) {
+	r, err := NewReader(strings.NewReader(roundTripVCF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "out.vcf")
+	w, err := NewWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(r.Header()); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range scanAll(t, r) {
+		if err := w.WriteVariant(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != roundTripVCF {
+		t.Errorf("Writer output = %q, want %q", got, roundTripVCF)
+	}
+}
+
+func TestWriter_percentEncoding(t *testing.T) {
+	const vcf = `##fileformat=VCFv4.3
+##INFO=<ID=NOTE,Number=.,Type=String,Description="Note">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=FT,Number=1,Type=String,Description="Sample filter">
+##contig=<ID=1,length=1000>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+1	100	.	A	C	.	.	NOTE=x	GT:FT	0/1:x
+`
+	for _, name := range []string{"out.vcf", "out.bcf"} {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(vcf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := scanAll(t, r)[0]
+			v.Info = map[string]string{"NOTE": "a;b=c,50%3B"}
+			g, err := NewGenotype("S1", map[string]string{"GT": "0/1", "FT": "d:e"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			v.genotypes = nil
+			if err := v.AddGenotype(g); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), name)
+			w, err := NewWriter(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteHeader(r.Header()); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteVariant(v); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f, err := New(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewScanner(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x := scanAll(t, s)[0]
+			note, err := x.AttributeAsStringSlice("NOTE")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"a;b=c", "50;"}; !reflect.DeepEqual(note, want) {
+				t.Errorf("NOTE = %q, want %q", note, want)
+			}
+			xg, err := x.Sample("S1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ft, err := xg.AttributeAsStringSlice("FT")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"d:e"}; !reflect.DeepEqual(ft, want) {
+				t.Errorf("FT = %q, want %q", ft, want)
+			}
+		})
+	}
+}